@@ -3,10 +3,12 @@
 package apdu
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"encoding/binary"
-	"encoding/hex"
 	"fmt"
 	"log/slog"
+	"slices"
 	"strings"
 )
 
@@ -41,21 +43,80 @@ const (
 	LenLcExtended = 3
 	// LenLeExtended defines the length of the Le of an extended APDU.
 	LenLeExtended = 2
+	// NeMaxStandard is the Ne requesting all available response data of a standard length command (Le 0x00).
+	NeMaxStandard = MaxLenResponseDataStandard
+	// NeMaxExtended is the Ne requesting all available response data of an extended length command (Le 0x0000).
+	NeMaxExtended = MaxLenResponseDataExtended
 	packageTag    = "apdu"
 )
 
 // Capdu is a Command APDU.
 type Capdu struct {
-	CLA  byte   // CLA is the class byte.
-	INS  byte   // INS is the instruction byte.
-	P1   byte   // P1 is the p1 byte.
-	P2   byte   // P2 is the p2 byte.
-	Data []byte // Data is the data field.
-	Ne   int    // Ne is the total number of expected response data byte (not LE encoded).
+	CLA byte // CLA is the class byte.
+	INS byte // INS is the instruction byte.
+	P1  byte // P1 is the p1 byte.
+	P2  byte // P2 is the p2 byte.
+	// ZeroLe marks a Capdu with Ne 0 carrying an explicit standard length Le of 0x00, as parsed with
+	// ParseOptions.LeZeroMeansZero. EncodeOptions.LeZeroMeansZero emits the Le for it, Bytes ignores it as ISO 7816-4
+	// has no encoding for Ne 0 with an Le present.
+	ZeroLe bool
+	Data   []byte // Data is the data field.
+	Ne     int    // Ne is the total number of expected response data byte (not LE encoded).
+	// ExpectedNc is the optional expected length of Data. If non-zero Validate checks it against the length of Data to
+	// catch truncated data, it is purely a validation aid and does not change the encoding. The effective number of
+	// command data byte as returned by Nc is always len(Data).
+	ExpectedNc int
+	// Label is an optional human readable annotation such as "Select MF" which is included in LogValue but is not part
+	// of the encoding and ignored by Equal.
+	Label string
 }
 
-// ParseCapdu parses a Command APDU and returns a Capdu.
+// NewCapdu returns a Capdu with the given header, data and ne. All other fields are zero, so unlike modifying a
+// reused Capdu no ExpectedNc or Label of a previous command is carried over.
+func NewCapdu(cla, ins, p1, p2 byte, data []byte, ne int) Capdu {
+	return Capdu{CLA: cla, INS: ins, P1: p1, P2: p2, Data: data, Ne: ne}
+}
+
+// NewCapduP1 returns a Capdu like NewCapdu for the common shape of commands with P2 0x00.
+func NewCapduP1(cla, ins, p1 byte, data []byte, ne int) Capdu {
+	return NewCapdu(cla, ins, p1, 0x00, data, ne)
+}
+
+// NewCase2Max returns a Case 2 Capdu requesting all available response data, i.e. with Ne set to NeMaxExtended if
+// extended is true and NeMaxStandard otherwise.
+func NewCase2Max(cla, ins, p1, p2 byte, extended bool) Capdu {
+	ne := NeMaxStandard
+	if extended {
+		ne = NeMaxExtended
+	}
+
+	return NewCapdu(cla, ins, p1, p2, nil, ne)
+}
+
+// ParseOptions configures non ISO 7816-4 compliant behaviour when parsing APDUs. The zero value parses according to
+// ISO 7816-4 as ParseCapdu does.
+type ParseOptions struct {
+	// LeZeroMeansZero disables the promotion of a standard length Le of 0x00 to Ne 256 for Case 2 and Case 4 commands,
+	// instead Ne is 0 and ZeroLe is set. Use EncodeOptions with the same flag to encode such commands.
+	LeZeroMeansZero bool
+	// AllowStandardLcExtendedLe accepts commands with a one byte standard Lc and data followed by a two byte extended
+	// Le, as emitted by some readers. Bytes re-encodes such commands in the compliant form for their Nc and Ne, e.g.
+	// standard form if Ne fits a standard Le.
+	AllowStandardLcExtendedLe bool
+	// AllowExtendedSingleZeroLe accepts extended length commands with data followed by a single 0x00 Le instead of the
+	// two byte 0x0000, as emitted by some terminals, as Ne 65536. This is a non-compliant tolerance, Bytes re-encodes
+	// such commands with a two byte Le.
+	AllowExtendedSingleZeroLe bool
+}
+
+// ParseCapdu parses a Command APDU and returns a Capdu. The length of the command disambiguates the byte following
+// the header, a 5 byte command is always a standard Case 2 with that byte as Le, in longer commands it is the Lc.
 func ParseCapdu(c []byte) (Capdu, error) {
+	return ParseOptions{}.ParseCapdu(c)
+}
+
+// ParseCapdu parses a Command APDU according to the options and returns a Capdu.
+func (o ParseOptions) ParseCapdu(c []byte) (Capdu, error) {
 	if len(c) < LenHeader || len(c) > 65544 {
 		return Capdu{}, fmt.Errorf("%s: invalid length - Capdu must consist of at least 4 byte and maximum of 65544 byte, got %d", packageTag, len(c))
 	}
@@ -67,9 +128,13 @@ func ParseCapdu(c []byte) (Capdu, error) {
 
 	// STANDARD CASE 2 command: HEADER | Le
 	if len(c) == LenHeader+LenLeStandard {
-		// in this case, no Lc is present
+		// in this case, no Lc is present, a fifth byte is never an Lc as it would require at least one data byte
 		ne := int(c[OffsetLcStandard])
 		if ne == 0 {
+			if o.LeZeroMeansZero {
+				return Capdu{CLA: c[OffsetCLA], INS: c[OffsetINS], P1: c[OffsetP1], P2: c[OffsetP2], ZeroLe: true}, nil
+			}
+
 			return Capdu{CLA: c[OffsetCLA], INS: c[OffsetINS], P1: c[OffsetP1], P2: c[OffsetP2], Data: nil, Ne: MaxLenResponseDataStandard}, nil
 		}
 
@@ -98,6 +163,7 @@ func ParseCapdu(c []byte) (Capdu, error) {
 		// if standard the Lc byte should have been omitted when there is no command.
 		// The sanest interpretation is this should have been a standard case 2 but the Lc byte was accidentally included
 		// For safety only handle the case of Ne == 256 as this is the only case seen in the wild.
+		// Any other byte following a zero Lc (e.g. 00 A4 04 00 00 05) is a malformed case 4 without data and rejected.
 		if len(c) == LenHeader+2 {
 			le := c[5]
 			if le != 0 {
@@ -109,6 +175,12 @@ func ParseCapdu(c []byte) (Capdu, error) {
 		bodyLen := len(c) - LenHeader
 
 		lc := int(binary.BigEndian.Uint16(c[OffsetLcExtended:]))
+
+		// Non-compliant extended encoding: HEADER | Lc | DATA | 00
+		if o.AllowExtendedSingleZeroLe && lc > 0 && lc == bodyLen-LenLcExtended-1 && c[len(c)-1] == 0x00 {
+			return Capdu{CLA: c[OffsetCLA], INS: c[OffsetINS], P1: c[OffsetP1], P2: c[OffsetP2], Data: c[OffsetCdataExtended : OffsetCdataExtended+lc], Ne: MaxLenResponseDataExtended}, nil
+		}
+
 		if lc != bodyLen-LenLcExtended && lc != bodyLen-LenLcExtended-LenLeExtended {
 			return Capdu{}, fmt.Errorf("%s: invalid Lc value - Lc indicates data length %d", packageTag, lc)
 		}
@@ -136,6 +208,17 @@ func ParseCapdu(c []byte) (Capdu, error) {
 
 	// check if Lc indicates valid length
 	lc := int(c[OffsetLcStandard])
+
+	// Non-compliant mixed encoding: HEADER | Lc | DATA | extended Le
+	if o.AllowStandardLcExtendedLe && lc == bodyLen-LenLcStandard-LenLeExtended {
+		ne := int(binary.BigEndian.Uint16(c[len(c)-LenLeExtended:]))
+		if ne == 0 {
+			ne = MaxLenResponseDataExtended
+		}
+
+		return Capdu{CLA: c[OffsetCLA], INS: c[OffsetINS], P1: c[OffsetP1], P2: c[OffsetP2], Data: c[OffsetCdataStandard : OffsetCdataStandard+lc], Ne: ne}, nil
+	}
+
 	if lc != bodyLen-LenLcStandard && lc != bodyLen-LenLcStandard-1 {
 		return Capdu{}, fmt.Errorf("%s: invalid Lc value - Lc indicates length %d", packageTag, lc)
 	}
@@ -150,6 +233,10 @@ func ParseCapdu(c []byte) (Capdu, error) {
 	var ne int
 	// STANDARD CASE 4 command: HEADER | Lc | DATA | Le
 	if le := int(c[len(c)-1]); le == 0 {
+		if o.LeZeroMeansZero {
+			return Capdu{CLA: c[OffsetCLA], INS: c[OffsetINS], P1: c[OffsetP1], P2: c[OffsetP2], ZeroLe: true, Data: data}, nil
+		}
+
 		ne = MaxLenResponseDataStandard
 	} else {
 		ne = le
@@ -158,116 +245,811 @@ func ParseCapdu(c []byte) (Capdu, error) {
 	return Capdu{CLA: c[OffsetCLA], INS: c[OffsetINS], P1: c[OffsetP1], P2: c[OffsetP2], Data: data, Ne: ne}, nil
 }
 
+// ParseCapduBody assembles a Capdu from a header and data already separated by an outer framing layer, such as a
+// TLV based transport carrying the data length itself. No Lc or Le is inferred, data and ne are used as given.
+// Data aliases data and is nil if data is empty.
+func ParseCapduBody(header [LenHeader]byte, data []byte, ne int) Capdu {
+	if len(data) == 0 {
+		data = nil
+	}
+
+	return Capdu{CLA: header[OffsetCLA], INS: header[OffsetINS], P1: header[OffsetP1], P2: header[OffsetP2], Data: data, Ne: ne}
+}
+
+// LcLe holds the raw length fields of a Command APDU as returned by ParseCapduDetailed.
+type LcLe struct {
+	// LcBytes is the encoded Lc, one byte for standard length or three byte including the leading 0x00 for extended
+	// length. It is nil if no Lc is present.
+	LcBytes []byte
+	// LeBytes is the encoded Le, one byte for standard length, two byte for extended length or three byte including the
+	// leading 0x00 for an extended length Case 2 command. It is nil if no Le is present.
+	LeBytes []byte
+	// Extended is true if the length fields are encoded in extended form.
+	Extended bool
+}
+
+// ParseCapduDetailed parses a Command APDU like ParseCapdu and additionally returns the raw Lc and Le fields as they
+// were encoded, e.g. to show non-canonical encodings. The returned slices alias c.
+func ParseCapduDetailed(c []byte) (Capdu, LcLe, error) {
+	capdu, err := ParseCapdu(c)
+	if err != nil {
+		return Capdu{}, LcLe{}, err
+	}
+
+	body := c[LenHeader:]
+
+	// the length fields are split following the same layouts as ParseCapdu, so an extended Lc of 0x0000 followed by an
+	// Le is reported as such even though the parsed Capdu has no data
+	lcLen := LenLcStandard
+	switch {
+	case len(body) == 0:
+		return capdu, LcLe{}, nil
+	case len(body) == LenLeStandard:
+		return capdu, LcLe{LeBytes: body}, nil
+	case body[0] != 0x00:
+	case len(body) == 1+LenLeExtended:
+		return capdu, LcLe{LeBytes: body, Extended: true}, nil
+	case len(body) == 2:
+		// HID hack, the zero Lc byte should have been omitted
+		return capdu, LcLe{LcBytes: body[:1], LeBytes: body[1:]}, nil
+	default:
+		lcLen = LenLcExtended
+	}
+
+	lcle := LcLe{LcBytes: body[:lcLen], Extended: lcLen == LenLcExtended}
+	if le := body[lcLen+len(capdu.Data):]; len(le) > 0 {
+		lcle.LeBytes = le
+	}
+
+	return capdu, lcle, nil
+}
+
+// PeekCase returns the case (1 to 4) of a Command APDU and whether it is encoded in extended form, inspecting only the
+// length fields. The classification is the same ParseCapdu applies, but no Capdu is built and nothing is allocated
+// for valid input.
+func PeekCase(c []byte) (caseNum int, extended bool, err error) {
+	if len(c) < LenHeader || len(c) > 65544 {
+		return 0, false, fmt.Errorf("%s: invalid length - Capdu must consist of at least 4 byte and maximum of 65544 byte, got %d", packageTag, len(c))
+	}
+
+	switch {
+	case len(c) == LenHeader:
+		return 1, false, nil
+	case len(c) == LenHeader+LenLeStandard:
+		return 2, false, nil
+	case c[OffsetLcStandard] != 0x00:
+		bodyLen := len(c) - LenHeader
+		switch lc := int(c[OffsetLcStandard]); lc {
+		case bodyLen - LenLcStandard:
+			return 3, false, nil
+		case bodyLen - LenLcStandard - LenLeStandard:
+			return 4, false, nil
+		default:
+			return 0, false, fmt.Errorf("%s: invalid Lc value - Lc indicates length %d", packageTag, lc)
+		}
+	case len(c) == LenHeader+1+LenLeExtended:
+		return 2, true, nil
+	case len(c) == LenHeader+2:
+		// Dodgy broken HID reader request, see ParseCapdu
+		if le := c[5]; le != 0 {
+			return 0, false, fmt.Errorf("%s: invalid Le value %d in HID hack handler", packageTag, le)
+		}
+		return 2, false, nil
+	}
+
+	bodyLen := len(c) - LenHeader
+	switch lc := int(binary.BigEndian.Uint16(c[OffsetLcExtended:])); lc {
+	case bodyLen - LenLcExtended:
+		return 3, true, nil
+	case bodyLen - LenLcExtended - LenLeExtended:
+		if lc == 0 {
+			// an Lc of 0x0000 followed by an Le carries no data, ParseCapdu treats it as Case 2
+			return 2, true, nil
+		}
+		return 4, true, nil
+	default:
+		return 0, false, fmt.Errorf("%s: invalid Lc value - Lc indicates data length %d", packageTag, lc)
+	}
+}
+
 // ParseCapduHexString decodes the hex-string representation of a Command APDU, calls ParseCapdu and returns a Capdu.
 func ParseCapduHexString(s string) (Capdu, error) {
+	c, _, err := ParseCapduHexStringInto(nil, s)
+
+	return c, err
+}
+
+// ParseCapduHexStringInto works like ParseCapduHexString but decodes into dst, which is only reallocated if its
+// capacity is too small. It returns the possibly grown buffer for reuse by the next call. The returned Capdu aliases
+// the buffer and is only valid until it is reused.
+func ParseCapduHexStringInto(dst []byte, s string) (Capdu, []byte, error) {
 	if len(s)%2 != 0 {
-		return Capdu{}, fmt.Errorf("%s: uneven number of hex characters", packageTag)
+		return Capdu{}, dst, fmt.Errorf("%s: uneven number of hex characters", packageTag)
 	}
 
 	if len(s) < 8 || len(s) > 65544*2 {
-		return Capdu{}, fmt.Errorf("%s: invalid length of hex string - a Capdu must consist of at least 4 byte and maximum of 65544 byte, got %d", packageTag, len(s)/2)
+		return Capdu{}, dst, fmt.Errorf("%s: invalid length of hex string - a Capdu must consist of at least 4 byte and maximum of 65544 byte, got %d", packageTag, len(s)/2)
 	}
 
-	b, err := hex.DecodeString(s)
-	if err != nil {
-		return Capdu{}, fmt.Errorf("%w: %s: hex conversion error", err, packageTag)
+	if cap(dst) < len(s)/2 {
+		dst = make([]byte, len(s)/2)
+	}
+	dst = dst[:len(s)/2]
+
+	if err := decodeHexString(dst, s); err != nil {
+		return Capdu{}, dst, fmt.Errorf("%w: %s: hex conversion error", err, packageTag)
 	}
 
-	return ParseCapdu(b)
+	c, err := ParseCapdu(dst)
+
+	return c, dst, err
 }
 
-// Bytes returns the byte representation of the Capdu.
-func (c Capdu) Bytes() ([]byte, error) {
-	dataLen := len(c.Data)
+// ParseCapduHexStringLenient calls ParseCapduHexString after removing whitespace and colon separators as well as 0x
+// prefixes, e.g. "00 A4 04 00", "00:A4:04:00" or "0x00 0xA4 0x04 0x00". Upper and lower case hex digits are accepted.
+func ParseCapduHexStringLenient(s string) (Capdu, error) {
+	return ParseCapduHexString(normalizeHex(s))
+}
+
+// ParseCapduCString calls ParseCapduHexStringLenient with the part of s before the first NUL character, as handed
+// over as null-terminated C string, ignoring anything following it. An error is returned if there is no hex before
+// the NUL.
+func ParseCapduCString(s string) (Capdu, error) {
+	if i := strings.IndexByte(s, 0x00); i >= 0 {
+		s = s[:i]
+	}
+
+	if strings.TrimSpace(s) == "" {
+		return Capdu{}, fmt.Errorf("%s: no hex characters before NUL", packageTag)
+	}
+
+	return ParseCapduHexStringLenient(s)
+}
+
+// Validate checks that the Capdu can be encoded: the length of Data and Ne must not exceed the extended length limits
+// and Data must have the length given by ExpectedNc if set.
+func (c Capdu) Validate() error {
+	if len(c.Data) > MaxLenCommandDataExtended {
+		return fmt.Errorf("%s: len of Capdu.Data %d exceeds maximum allowed length of %d", packageTag, len(c.Data), MaxLenCommandDataExtended)
+	}
 
-	if dataLen > MaxLenCommandDataExtended {
-		return nil, fmt.Errorf("%s: len of Capdu.Data %d exceeds maximum allowed length of %d", packageTag, len(c.Data), MaxLenCommandDataExtended)
+	if c.ExpectedNc != 0 && c.ExpectedNc != len(c.Data) {
+		return fmt.Errorf("%s: len of Capdu.Data %d does not match ExpectedNc %d", packageTag, len(c.Data), c.ExpectedNc)
 	}
 
 	if c.Ne > MaxLenResponseDataExtended {
-		return nil, fmt.Errorf("%s: ne %d exceeds maximum allowed length of %d", packageTag, len(c.Data), MaxLenResponseDataExtended)
+		return fmt.Errorf("%s: ne %d exceeds maximum allowed length of %d", packageTag, c.Ne, MaxLenResponseDataExtended)
 	}
 
-	if dataLen > MaxLenCommandDataStandard || c.Ne > MaxLenResponseDataStandard {
-		return c.BytesExtended()
+	return nil
+}
+
+// Bytes returns the byte representation of the Capdu.
+func (c Capdu) Bytes() ([]byte, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
 	}
 
-	switch {
-	case len(c.Data) == 0 && c.Ne == 0:
-		// CASE 1: HEADER
-		return []byte{c.CLA, c.INS, c.P1, c.P2}, nil
-	case len(c.Data) == 0 && c.Ne > 0:
-		// CASE 2: HEADER | Le
-		return []byte{c.CLA, c.INS, c.P1, c.P2, (byte)((c.Ne) & 0xFF)}, nil
-	case len(c.Data) != 0 && c.Ne == 0:
-		// CASE 3: HEADER | Lc | DATA
-		result := make([]byte, 0, LenHeader+LenLcStandard+dataLen)
-		result = append(result, c.CLA, c.INS, c.P1, c.P2, byte(dataLen))
-		result = append(result, c.Data...)
+	extended := c.IsExtendedLength()
+
+	return c.appendBytes(make([]byte, 0, c.encodedLen(extended)), extended), nil
+}
+
+// EncodeOptions configures non ISO 7816-4 compliant behaviour when encoding APDUs. The zero value encodes according
+// to ISO 7816-4 as Capdu.Bytes does.
+type EncodeOptions struct {
+	// LeZeroMeansZero indicates a standard length Le of 0x00 means Ne 0 rather than 256, matching
+	// ParseOptions.LeZeroMeansZero. Ne 256 is then encoded in extended form, and Ne 0 is encoded with an Le of 0x00 if
+	// ZeroLe is set or without Le otherwise, so commands parsed with ParseOptions.LeZeroMeansZero round-trip.
+	LeZeroMeansZero bool
+}
 
-		return result, nil
+// Bytes returns the byte representation of the Capdu according to the options.
+func (o EncodeOptions) Bytes(c Capdu) ([]byte, error) {
+	if !o.LeZeroMeansZero {
+		return c.Bytes()
 	}
 
-	// CASE 4: HEADER | Lc | DATA | Le
-	result := make([]byte, 0, LenHeader+LenLcStandard+dataLen+LenLeStandard)
-	result = append(result, c.CLA, c.INS, c.P1, c.P2, byte(dataLen))
-	result = append(result, c.Data...)
-	result = append(result, byte(c.Ne))
+	switch {
+	case c.Ne == MaxLenResponseDataStandard:
+		return c.BytesExtended()
+	case c.Ne == 0 && c.ZeroLe:
+		if err := c.Validate(); err != nil {
+			return nil, err
+		}
 
-	return result, nil
+		if c.IsExtendedLength() {
+			return nil, fmt.Errorf("%s: zero Le can not be encoded with extended length data of %d byte", packageTag, len(c.Data))
+		}
+
+		return append(c.appendBytes(make([]byte, 0, c.encodedLen(false)+LenLeStandard), false), 0x00), nil
+	default:
+		return c.Bytes()
+	}
 }
 
 // BytesExtended returns the byte representation of the Capdu forcing extended form.
 // If both Nc and Ne are 0 then Ne will be treated as MaxLenResponseDataExtended to force extended APDU form
 func (c Capdu) BytesExtended() ([]byte, error) {
-	dataLen := len(c.Data)
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
 
-	if dataLen > MaxLenCommandDataExtended {
-		return nil, fmt.Errorf("%s: len of Capdu.Data %d exceeds maximum allowed length of %d", packageTag, len(c.Data), MaxLenCommandDataExtended)
+	return c.appendBytes(make([]byte, 0, c.encodedLen(true)), true), nil
+}
+
+// PromoteToExtended parses the Command APDU b and returns it re-encoded in extended length form with BytesExtended,
+// e.g. for a card only accepting extended length commands. Ne is preserved, an Le of 0x00 meaning 256 is encoded as
+// 0x0100. A Case 1 command has no length fields and is returned as a copy as it has no extended form.
+func PromoteToExtended(b []byte) ([]byte, error) {
+	c, err := ParseCapdu(b)
+	if err != nil {
+		return nil, err
 	}
 
-	if c.Ne > MaxLenResponseDataExtended {
-		return nil, fmt.Errorf("%s: ne %d exceeds maximum allowed length of %d", packageTag, len(c.Data), MaxLenResponseDataExtended)
+	if c.Case() == 1 {
+		return c.Bytes()
+	}
+
+	return c.BytesExtended()
+}
+
+// DemoteToStandard parses the Command APDU b and returns it re-encoded in standard length form. Ne is preserved, an
+// extended Le of 0x0100 is encoded as 0x00. An error is returned if the command RequiresExtended.
+func DemoteToStandard(b []byte) ([]byte, error) {
+	c, err := ParseCapdu(b)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.RequiresExtended() {
+		return nil, fmt.Errorf("%s: command requires extended length (data length %d, ne %d)", packageTag, len(c.Data), c.Ne)
+	}
+
+	return c.Bytes()
+}
+
+// EncodeInto writes the byte representation of the Capdu as returned by Bytes into buf and returns the number of
+// byte written. Unlike Bytes it never allocates, an error is returned if buf is too small.
+func (c Capdu) EncodeInto(buf []byte) (n int, err error) {
+	if err = c.Validate(); err != nil {
+		return 0, err
+	}
+
+	extended := c.IsExtendedLength()
+
+	n = c.encodedLen(extended)
+	if len(buf) < n {
+		return 0, fmt.Errorf("%s: buffer of %d byte too small for Capdu of %d byte", packageTag, len(buf), n)
+	}
+
+	c.appendBytes(buf[:0], extended)
+
+	return n, nil
+}
+
+// Len returns the length of the byte representation of the Capdu as returned by Bytes.
+func (c Capdu) Len() (int, error) {
+	if err := c.Validate(); err != nil {
+		return 0, err
+	}
+
+	return c.encodedLen(c.IsExtendedLength()), nil
+}
+
+// FitsIFS returns true if the byte representation of the Capdu as returned by Bytes fits into the information field
+// of a single T=1 block of size ifs, otherwise the block layer chains it over multiple I-blocks. This is unrelated to
+// command chaining at the APDU level. False is returned if the Capdu cannot be encoded.
+func (c Capdu) FitsIFS(ifs int) bool {
+	n, err := c.Len()
+	return err == nil && n <= ifs
+}
+
+// EncodeCapduBatch returns the byte representations of all cmds as returned by Bytes concatenated into a single
+// buffer, along with the offset of each command within it. The buffer is allocated once.
+// If a command can not be encoded the returned error includes its index.
+func EncodeCapduBatch(cmds []Capdu) ([]byte, []int, error) {
+	var total int
+	for i, c := range cmds {
+		n, err := c.Len()
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: command %d: %w", packageTag, i, err)
+		}
+		total += n
+	}
+
+	b := make([]byte, 0, total)
+	offsets := make([]int, len(cmds))
+	for i, c := range cmds {
+		offsets[i] = len(b)
+		b = c.appendBytes(b, c.IsExtendedLength())
+	}
+
+	return b, offsets, nil
+}
+
+// encodedLen returns the length of the byte representation of the Capdu in standard or extended form.
+func (c Capdu) encodedLen(extended bool) int {
+	dataLen := len(c.Data)
+
+	if extended {
+		n := LenHeader + LenLcExtended + dataLen
+		if dataLen == 0 {
+			// no Lc, only the leading zero byte before Le
+			n = LenHeader + 1
+		}
+		if c.Ne > 0 || dataLen == 0 {
+			n += LenLeExtended
+		}
+
+		return n
 	}
 
-	var leLen int
+	n := LenHeader
+	if dataLen > 0 {
+		n += LenLcStandard + dataLen
+	}
 	if c.Ne > 0 {
-		// if there is no Nc nor Ne then the Le bytes are covered by the Lc bytes in the buffer
-		leLen = LenLeExtended
+		n += LenLeStandard
+	}
+
+	return n
+}
+
+// appendBytes appends the byte representation of the Capdu in standard or extended form to dst.
+// The Capdu must be valid and fit the requested form.
+func (c Capdu) appendBytes(dst []byte, extended bool) []byte {
+	// CASE 1: HEADER
+	// CASE 2: HEADER | Le
+	// CASE 3: HEADER | Lc | DATA
+	// CASE 4: HEADER | Lc | DATA | Le
+	dst = c.appendHeaderLc(dst, extended)
+	dst = append(dst, c.Data...)
+
+	return c.appendLe(dst, extended)
+}
+
+// appendHeaderLc appends the header and the Lc of the Capdu in standard or extended form to dst, including the
+// leading zero byte of the extended form.
+func (c Capdu) appendHeaderLc(dst []byte, extended bool) []byte {
+	dataLen := len(c.Data)
+
+	dst = append(dst, c.CLA, c.INS, c.P1, c.P2)
+
+	if extended {
+		dst = append(dst, 0x00)
+		if dataLen > 0 {
+			dst = append(dst, (byte)((dataLen>>8)&0xFF), (byte)(dataLen&0xFF))
+		}
+
+		return dst
 	}
 
-	result := make([]byte, 0, LenHeader+LenLcExtended+dataLen+leLen)
-	result = append(result, c.CLA, c.INS, c.P1, c.P2, 0x00)
 	if dataLen > 0 {
-		result = append(result, (byte)((dataLen>>8)&0xFF), (byte)(dataLen&0xFF))
-		result = append(result, c.Data...)
+		dst = append(dst, byte(dataLen))
+	}
+
+	return dst
+}
+
+// appendLe appends the Le of the Capdu in standard or extended form to dst, nothing if no Le is encoded.
+func (c Capdu) appendLe(dst []byte, extended bool) []byte {
+	if extended {
+		if c.Ne > 0 || len(c.Data) == 0 {
+			// technically can't have an extended payload with both Nc == 0 and Ne == 0, so force adding a max length Ne
+			dst = append(dst, (byte)((c.Ne>>8)&0xFF), (byte)(c.Ne&0xFF))
+		}
+
+		return dst
 	}
-	if c.Ne > 0 || dataLen == 0 {
-		// technically can't have an extended payload with both Nc == 0 and Ne == 0, so force adding a max length Ne
-		result = append(result, (byte)((c.Ne>>8)&0xFF), (byte)(c.Ne&0xFF))
+
+	if c.Ne > 0 {
+		dst = append(dst, (byte)((c.Ne)&0xFF))
 	}
 
-	return result, nil
+	return dst
 }
 
-// String calls Bytes and returns the hex encoded string representation of the Capdu.
+// EncodedLe returns the Le as encoded by Bytes: empty for Case 1 and Case 3 commands, one byte for standard and two
+// byte for extended length. The leading 0x00 of an extended length Case 2 command is not part of the returned Le.
+func (c Capdu) EncodedLe() ([]byte, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	return c.appendLe(make([]byte, 0, LenLeExtended), c.IsExtendedLength()), nil
+}
+
+// String returns the hex encoded string representation of the encoding of the Capdu as returned by Bytes.
 func (c Capdu) String() (string, error) {
-	b, err := c.Bytes()
-	if err != nil {
+	return c.hexString(hexUpperDigits)
+}
+
+// StringLower returns the hex-string representation of the Capdu like String, but with lowercase hex digits.
+func (c Capdu) StringLower() (string, error) {
+	return c.hexString(hexLowerDigits)
+}
+
+// hexString encodes the Capdu as hex straight into the string without an intermediate byte slice, so only the
+// string itself is allocated.
+func (c Capdu) hexString(digits string) (string, error) {
+	if err := c.Validate(); err != nil {
 		return "", err
 	}
 
-	return strings.ToUpper(hex.EncodeToString(b)), nil
+	extended := c.IsExtendedLength()
+
+	var sb strings.Builder
+	sb.Grow(2 * c.encodedLen(extended))
+
+	var buf [LenHeader + 1 + LenLcExtended]byte
+	writeHex(&sb, c.appendHeaderLc(buf[:0], extended), digits)
+	writeHex(&sb, c.Data, digits)
+	writeHex(&sb, c.appendLe(buf[:0], extended), digits)
+
+	return sb.String(), nil
+}
+
+// AppendHex appends the uppercase hex-string representation of the Capdu as returned by String to dst and returns the
+// extended slice. The encoding is written directly into dst without an intermediate byte slice.
+func (c Capdu) AppendHex(dst []byte) ([]byte, error) {
+	return c.appendHex(dst, hexUpperDigits)
+}
+
+func (c Capdu) appendHex(dst []byte, digits string) ([]byte, error) {
+	if err := c.Validate(); err != nil {
+		return dst, err
+	}
+
+	extended := c.IsExtendedLength()
+	n := c.encodedLen(extended)
+
+	dst = slices.Grow(dst, 2*n)
+	dst = c.appendBytes(dst, extended)
+
+	return expandHex(dst, n, digits), nil
+}
+
+// GoString implements fmt.GoStringer for legible %#v output, printing the header in hex and Data as a hex string, e.g.
+// apdu.Capdu{CLA:0x00, INS:0xA4, P1:0x00, P2:0x0C, Data:hex"3F00", Ne:0}. ExpectedNc and Label are only included if
+// set.
+func (c Capdu) GoString() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "apdu.Capdu{CLA:0x%02X, INS:0x%02X, P1:0x%02X, P2:0x%02X, Data:%s, Ne:%d", c.CLA, c.INS, c.P1, c.P2, goStringHex(c.Data), c.Ne)
+	if c.ZeroLe {
+		sb.WriteString(", ZeroLe:true")
+	}
+	if c.ExpectedNc != 0 {
+		fmt.Fprintf(&sb, ", ExpectedNc:%d", c.ExpectedNc)
+	}
+	if c.Label != "" {
+		fmt.Fprintf(&sb, ", Label:%q", c.Label)
+	}
+	sb.WriteByte('}')
+
+	return sb.String()
 }
 
 func (c Capdu) LogValue() slog.Value {
-	return slog.GroupValue(
+	attrs := []slog.Attr{
 		slog.String("info", fmt.Sprintf("%02X %02X %02X %02X (%d)", c.CLA, c.INS, c.P1, c.P2, c.Ne)),
 		slog.String("data", fmt.Sprintf("%X", c.Data)),
-	)
+	}
+	if c.Label != "" {
+		attrs = append(attrs, slog.String("label", c.Label))
+	}
+
+	return slog.GroupValue(attrs...)
+}
+
+// LogAttrs returns the fields of the Capdu as individual attributes for flat log schemas: "cla", "ins", "p1", "p2"
+// and "data" as hex strings, "nc" (length of Data), "ne", "case" and "extended", plus "label" if set. Like LogValue
+// the data is included as is.
+func (c Capdu) LogAttrs() []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String("cla", fmt.Sprintf("%02X", c.CLA)),
+		slog.String("ins", fmt.Sprintf("%02X", c.INS)),
+		slog.String("p1", fmt.Sprintf("%02X", c.P1)),
+		slog.String("p2", fmt.Sprintf("%02X", c.P2)),
+		slog.Int("nc", len(c.Data)),
+		slog.Int("ne", c.Ne),
+		slog.Int("case", c.Case()),
+		slog.Bool("extended", c.IsExtendedLength()),
+		slog.String("data", fmt.Sprintf("%X", c.Data)),
+	}
+	if c.Label != "" {
+		attrs = append(attrs, slog.String("label", c.Label))
+	}
+
+	return attrs
+}
+
+// Equal returns true if both Capdus have the same header, Data, Ne and ZeroLe, i.e. they encode to the same bytes.
+// Label and ExpectedNc are ignored, use EqualIncludingLabel to also compare the Label.
+func (c Capdu) Equal(other Capdu) bool {
+	return c.CLA == other.CLA && c.INS == other.INS && c.P1 == other.P1 && c.P2 == other.P2 &&
+		c.Ne == other.Ne && c.ZeroLe == other.ZeroLe && bytes.Equal(c.Data, other.Data)
+}
+
+// EqualIncludingLabel returns true if both Capdus are Equal and have the same Label.
+func (c Capdu) EqualIncludingLabel(other Capdu) bool {
+	return c.Equal(other) && c.Label == other.Label
+}
+
+// EquivalentTo returns true if both Capdus would be processed identically by a card, i.e. they have the same header,
+// Data and effective Ne. How they were encoded does not matter: standard or extended length, Le 00 or extended Le 0100
+// for an Ne of 256 as well as nil or empty Data are all equivalent. Label and ExpectedNc are ignored.
+// As a Capdu only holds the decoded Ne this is the same comparison as Equal.
+func (c Capdu) EquivalentTo(other Capdu) bool {
+	return c.Equal(other)
 }
 
 // IsExtendedLength returns true if the Capdu has extended length (len of Data > 65535 or Ne > 65536), else false.
 func (c Capdu) IsExtendedLength() bool {
 	return c.Ne > MaxLenResponseDataStandard || len(c.Data) > MaxLenCommandDataStandard
 }
+
+// RequiresExtended returns true if the Capdu can only be encoded in extended length form (length of Data > 255 or
+// Ne > 256). It is the same check as IsExtendedLength.
+func (c Capdu) RequiresExtended() bool {
+	return c.IsExtendedLength()
+}
+
+// Transmittable returns an error if the Capdu is not valid or requires extended length while the card does not
+// support it as indicated by extendedSupported, e.g. from the card capabilities in the historical bytes.
+func (c Capdu) Transmittable(extendedSupported bool) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	if !extendedSupported && c.RequiresExtended() {
+		return fmt.Errorf("%s: command requires extended length (data length %d, ne %d) which is not supported by the card", packageTag, len(c.Data), c.Ne)
+	}
+
+	return nil
+}
+
+// Nc returns the number of command data byte (Nc in ISO 7816-4 terms), which is always len(Data) regardless of
+// ExpectedNc.
+func (c Capdu) Nc() int {
+	return len(c.Data)
+}
+
+// NeFitsStandard returns true if Ne can be represented by a standard length Le (Ne up to 256), regardless of the length
+// of Data. If it is false a standard length encoding would misrepresent Ne.
+func (c Capdu) NeFitsStandard() bool {
+	return c.Ne <= MaxLenResponseDataStandard
+}
+
+// GetResponse returns the GET RESPONSE command to fetch ne bytes of remaining response data for the Capdu, e.g. after a
+// '0x61xx' status word. The CLA of the GET RESPONSE addresses the same logical channel as the Capdu.
+func (c Capdu) GetResponse(ne int) Capdu {
+	var cla byte
+	if channel, ok := c.LogicalChannel(); ok {
+		// can not fail for a decoded channel without secure messaging and chaining
+		cla, _ = BuildCLA(0x00, channel, SecureMessagingNone, false)
+	}
+
+	return Capdu{CLA: cla, INS: InsGetResponse, P1: 0x00, P2: 0x00, Ne: ne}
+}
+
+// Key returns a stable key for the Capdu suitable as a map key, e.g. to cache responses to idempotent commands.
+// The key is the uppercase hex string of the encoding returned by Bytes, so distinct commands never share a key.
+// The logical channel bits of the CLA are part of the key, call WithoutLogicalChannel first to ignore them.
+// An error is returned if the Capdu cannot be encoded.
+func (c Capdu) Key() (string, error) {
+	return c.String()
+}
+
+// HashOptions configures how Hash computes the hash of a Capdu. The zero value hashes the encoding as is.
+type HashOptions struct {
+	// IgnoreLogicalChannel hashes the command as if sent on the basic logical channel, see WithoutLogicalChannel.
+	IgnoreLogicalChannel bool
+}
+
+// Hash returns the SHA-256 hash of the encoding returned by Bytes, e.g. as fixed size key of a response cache.
+// The logical channel bits of the CLA are part of the hash, use HashOptions to ignore them. An error is returned if
+// the Capdu cannot be encoded.
+func (c Capdu) Hash() ([32]byte, error) {
+	return HashOptions{}.Hash(c)
+}
+
+// Hash returns the SHA-256 hash of the encoding of c according to the options. An error is returned if c cannot be
+// encoded. The Label is not part of the hash.
+func (o HashOptions) Hash(c Capdu) ([32]byte, error) {
+	if o.IgnoreLogicalChannel {
+		c = c.WithoutLogicalChannel()
+	}
+
+	b, err := c.Bytes()
+	if err != nil {
+		return [32]byte{}, err
+	}
+
+	return sha256.Sum256(b), nil
+}
+
+// TrimTrailingSWOptions configures how TrimTrailingSW detects a trailing status word. The zero value accepts status
+// words that IsSuccess, IsWarning or IsError classify.
+type TrimTrailingSWOptions struct {
+	// Predicate reports whether sw looks like a status word, nil uses the default. Set it to match a specific
+	// integration.
+	Predicate func(sw uint16) bool
+}
+
+// TrimTrailingSW returns a copy of the Capdu without the last two bytes of Data if they look like a status word
+// according to the default TrimTrailingSWOptions, otherwise the Capdu is returned unchanged. The returned Data aliases
+// the original. This is a heuristic workaround for integrations which erroneously append the previous response status
+// word to the command data, it will also strip legitimate data ending in bytes that look like a status word.
+func (c Capdu) TrimTrailingSW() Capdu {
+	return TrimTrailingSWOptions{}.TrimTrailingSW(c)
+}
+
+// TrimTrailingSW returns a copy of c without the last two bytes of Data if they look like a status word according to
+// the options, see Capdu.TrimTrailingSW.
+func (o TrimTrailingSWOptions) TrimTrailingSW(c Capdu) Capdu {
+	if len(c.Data) < LenResponseTrailer {
+		return c
+	}
+
+	predicate := o.Predicate
+	if predicate == nil {
+		predicate = isTrailingSW
+	}
+
+	if !predicate(binary.BigEndian.Uint16(c.Data[len(c.Data)-LenResponseTrailer:])) {
+		return c
+	}
+
+	c.Data = c.Data[:len(c.Data)-LenResponseTrailer]
+
+	return c
+}
+
+func isTrailingSW(sw uint16) bool {
+	r := NewRapdu(nil, sw)
+	return r.IsSuccess() || r.IsWarning() || r.IsError()
+}
+
+// CapNe returns a copy of the Capdu with Ne limited to max, e.g. the size of the response buffer of the card. A Capdu
+// without Ne (0) is left unchanged, as is any Capdu if max is not positive. Ne is the decoded number of expected byte,
+// so capping an extended Ne of 65536 to 256 yields a standard length Capdu with Le 0x00.
+func (c Capdu) CapNe(max int) Capdu {
+	if max > 0 && c.Ne > max {
+		c.Ne = max
+	}
+
+	return c
+}
+
+// ResponseShortfall returns how many byte of response data r is short of Ne, i.e. Ne minus the length of r.Data.
+// Ne is already the decoded number of expected byte, so an Le of 0x00 counts as 256 (standard) or 65536 (extended)
+// byte. The result is 0 if no data was expected or at least Ne byte were returned, a positive value indicates a short
+// response which may warrant a follow-up read.
+func (c Capdu) ResponseShortfall(r Rapdu) int {
+	ne := min(c.Ne, MaxLenResponseDataExtended)
+
+	return max(ne-len(r.Data), 0)
+}
+
+// LikelyTruncated returns true if r is a successful response ('0x61xx' or '0x9000') to the Capdu carrying exactly Ne
+// byte of data, which suggests the card truncated the response to Le and more data may be available.
+// This is a heuristic: a response which happens to be exactly Ne byte long is indistinguishable from a truncated one,
+// and cards may also truncate with warnings such as '0x6282' which are not considered here.
+func (c Capdu) LikelyTruncated(r Rapdu) bool {
+	return c.Ne > 0 && len(r.Data) == c.Ne && r.IsSuccess()
+}
+
+// ForPCSC returns the byte representation of the Capdu as returned by Bytes together with the receive buffer length
+// to pass to SCardTransmit, which is Ne plus the two status word byte. An error is returned for a negative Ne as it
+// has no valid receive buffer length.
+func (c Capdu) ForPCSC() (command []byte, recvLen int, err error) {
+	if c.Ne < 0 {
+		return nil, 0, fmt.Errorf("%s: ne %d must not be negative", packageTag, c.Ne)
+	}
+
+	command, err = c.Bytes()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return command, c.Ne + LenResponseTrailer, nil
+}
+
+// Case returns the ISO 7816-4 case of the Capdu as encoded by Bytes: 1 (no data, no Ne), 2 (Ne only), 3 (data only)
+// or 4 (data and Ne).
+func (c Capdu) Case() int {
+	switch {
+	case len(c.Data) == 0 && c.Ne <= 0:
+		return 1
+	case len(c.Data) == 0:
+		return 2
+	case c.Ne <= 0:
+		return 3
+	}
+
+	return 4
+}
+
+// IsCase1 returns true if the Capdu is a Case 1 command (no data, no Ne), otherwise false.
+func (c Capdu) IsCase1() bool {
+	return c.Case() == 1
+}
+
+// IsCase2 returns true if the Capdu is a Case 2 command (Ne only), otherwise false.
+func (c Capdu) IsCase2() bool {
+	return c.Case() == 2
+}
+
+// IsCase3 returns true if the Capdu is a Case 3 command (data only), otherwise false.
+func (c Capdu) IsCase3() bool {
+	return c.Case() == 3
+}
+
+// IsCase4 returns true if the Capdu is a Case 4 command (data and Ne), otherwise false.
+func (c Capdu) IsCase4() bool {
+	return c.Case() == 4
+}
+
+// ValidateResponse returns an error if r carries more response data than the Capdu asked for with Ne. Ne is already
+// the decoded number of expected byte, so an Le of 0x00 allows 256 (standard) or 65536 (extended) byte, and a Capdu
+// without Ne allows no response data at all.
+func (c Capdu) ValidateResponse(r Rapdu) error {
+	if len(r.Data) > c.Ne {
+		return fmt.Errorf("%s: response data length %d exceeds ne %d", packageTag, len(r.Data), c.Ne)
+	}
+
+	return nil
+}
+
+// CompareBytes encodes a and b with Bytes and compares the results byte by byte. It returns the offset of the first
+// differing byte, or -1 if both encodings are equal. If one encoding is a prefix of the other, the offset is the length
+// of the shorter one.
+func CompareBytes(a, b Capdu) (firstDiff int, equal bool, err error) {
+	ab, err := a.Bytes()
+	if err != nil {
+		return 0, false, fmt.Errorf("%s: encoding a: %w", packageTag, err)
+	}
+
+	bb, err := b.Bytes()
+	if err != nil {
+		return 0, false, fmt.Errorf("%s: encoding b: %w", packageTag, err)
+	}
+
+	for i := range min(len(ab), len(bb)) {
+		if ab[i] != bb[i] {
+			return i, false, nil
+		}
+	}
+
+	if len(ab) != len(bb) {
+		return min(len(ab), len(bb)), false, nil
+	}
+
+	return -1, true, nil
+}
+
+// SelfConsistent returns true if the Capdu can be encoded and the Lc of its encoding matches the length of Data.
+// It is an invariant check, a Capdu returned by ParseCapdu is always self-consistent.
+func (c Capdu) SelfConsistent() bool {
+	b, err := c.Bytes()
+	if err != nil {
+		return false
+	}
+
+	caseNum, extended, err := PeekCase(b)
+	if err != nil {
+		return false
+	}
+
+	var lc int
+	switch {
+	case caseNum <= 2:
+	case extended:
+		lc = int(binary.BigEndian.Uint16(b[OffsetLcExtended:]))
+	default:
+		lc = int(b[OffsetLcStandard])
+	}
+
+	return lc == len(c.Data)
+}
@@ -1,11 +1,98 @@
 package apdu_test
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"github.com/nvx/go-apdu"
+	"log/slog"
 	"reflect"
+	"strings"
 	"testing"
 )
 
+func TestNewCapdu(t *testing.T) {
+	t.Parallel()
+
+	got := apdu.NewCapdu(0x00, 0xA4, 0x04, 0x0C, []byte{0x01, 0x02}, 256)
+	want := apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x0C, Data: []byte{0x01, 0x02}, Ne: 256}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("NewCapdu() got = %v, want %v", got, want)
+	}
+}
+
+func TestNewCapduP1(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		data []byte
+		ne   int
+		want apdu.Capdu
+	}{
+		{
+			name: "case 1",
+			want: apdu.Capdu{CLA: 0x80, INS: 0xCA, P1: 0x9F},
+		},
+		{
+			name: "case 4",
+			data: []byte{0x01},
+			ne:   256,
+			want: apdu.Capdu{CLA: 0x80, INS: 0xCA, P1: 0x9F, P2: 0x00, Data: []byte{0x01}, Ne: 256},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := apdu.NewCapduP1(0x80, 0xCA, 0x9F, tt.data, tt.ne)
+			if got.P2 != 0x00 {
+				t.Errorf("NewCapduP1() P2 = %02X, want 00", got.P2)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewCapduP1() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewCase2Max(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		extended bool
+		want     string
+	}{
+		{
+			name: "standard",
+			want: "00B0000000",
+		},
+		{
+			name:     "extended",
+			extended: true,
+			want:     "00B00000000000",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := apdu.NewCase2Max(0x00, 0xB0, 0x00, 0x00, tt.extended).String()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("NewCase2Max() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestParseCapdu(t *testing.T) {
 	t.Parallel()
 
@@ -39,6 +126,17 @@ func TestParseCapdu(t *testing.T) {
 			args:    args{[]byte{0x00, 0xA4, 0x04, 0x01, 0x00, 0x00, 0x05, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}},
 			wantErr: true,
 		},
+		{
+			name:    "error: standard length Lc zero with Le",
+			args:    args{[]byte{0x00, 0xA4, 0x04, 0x00, 0x00, 0x05}},
+			wantErr: true,
+		},
+		{
+			// a zero byte after the header always indicates extended length, so there is no standard length Lc of zero
+			name:    "error: zero Lc with data and Le read as extended Lc",
+			args:    args{[]byte{0x00, 0xA4, 0x04, 0x00, 0x00, 0x01, 0x01, 0x00}},
+			wantErr: true,
+		},
 		{
 			name:    "Case 1",
 			args:    args{[]byte{0x00, 0xA4, 0x04, 0x00}},
@@ -124,39 +222,291 @@ func TestParseCapdu(t *testing.T) {
 	}
 }
 
-func TestParseCapduHexString(t *testing.T) {
+func TestParseCapdu_MaxLength(t *testing.T) {
 	t.Parallel()
 
-	type args struct {
-		s string
+	data := make([]byte, apdu.MaxLenCommandDataExtended)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	maxCapdu := make([]byte, 0, 65545)
+	maxCapdu = append(maxCapdu, 0x00, 0xDA, 0x01, 0x02, 0x00, 0xFF, 0xFF)
+	maxCapdu = append(maxCapdu, data...)
+	maxCapdu = append(maxCapdu, 0x00, 0x00)
+
+	if len(maxCapdu) != 65544 {
+		t.Fatalf("test setup: len = %d, want 65544", len(maxCapdu))
+	}
+
+	want := apdu.Capdu{CLA: 0x00, INS: 0xDA, P1: 0x01, P2: 0x02, Data: data, Ne: apdu.MaxLenResponseDataExtended}
+
+	got, err := apdu.ParseCapdu(maxCapdu)
+	if err != nil {
+		t.Fatalf("ParseCapdu() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseCapdu() got = %v, want %v", got, want)
+	}
+
+	b, err := got.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes() error = %v", err)
+	}
+	if !reflect.DeepEqual(b, maxCapdu) {
+		t.Errorf("Bytes() does not reproduce the %d byte Capdu, got %d byte", len(maxCapdu), len(b))
+	}
+
+	if _, err = apdu.ParseCapdu(append(maxCapdu, 0x00)); err == nil {
+		t.Errorf("ParseCapdu() of 65545 byte expected error")
+	}
+
+	if _, err = apdu.ParseCapduHexString(hex.EncodeToString(maxCapdu)); err != nil {
+		t.Errorf("ParseCapduHexString() of 65544 byte error = %v", err)
+	}
+
+	for _, ne := range []int{1, 256, 65535} {
+		c := apdu.Capdu{CLA: 0x00, INS: 0xDA, P1: 0x01, P2: 0x02, Data: data, Ne: ne}
+
+		b, err := c.Bytes()
+		if err != nil {
+			t.Fatalf("Bytes() Ne %d error = %v", ne, err)
+		}
+		if len(b) != 65544 {
+			t.Errorf("Bytes() Ne %d got len %d, want 65544", ne, len(b))
+		}
+
+		got, err := apdu.ParseCapdu(b)
+		if err != nil {
+			t.Fatalf("ParseCapdu() Ne %d error = %v", ne, err)
+		}
+		if !reflect.DeepEqual(got, c) {
+			t.Errorf("ParseCapdu() Ne %d does not round-trip", ne)
+		}
 	}
+}
+
+func TestParseOptions_LeZeroMeansZero(t *testing.T) {
+	t.Parallel()
 
 	tests := []struct {
-		name    string
-		args    args
-		want    apdu.Capdu
-		wantErr bool
+		name string
+		c    []byte
+		want apdu.Capdu
 	}{
 		{
-			name:    "error: uneven number bytes",
-			args:    args{s: "000102030"},
-			wantErr: true,
+			name: "Case 2 standard length Le equal zero",
+			c:    []byte{0x00, 0xB0, 0x00, 0x00, 0x00},
+			want: apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, ZeroLe: true},
 		},
 		{
-			name:    "error: invalid length",
-			args:    args{s: "000102"},
+			name: "Case 2 standard length Le unequal zero",
+			c:    []byte{0x00, 0xB0, 0x00, 0x00, 0x05},
+			want: apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Ne: 5},
+		},
+		{
+			name: "Case 4 standard length Le equal zero",
+			c:    []byte{0x00, 0xA4, 0x04, 0x00, 0x02, 0x01, 0x02, 0x00},
+			want: apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, ZeroLe: true, Data: []byte{0x01, 0x02}},
+		},
+		{
+			name: "Case 2 extended length Le equal zero unaffected",
+			c:    []byte{0x00, 0xB0, 0x00, 0x00, 0x00, 0x00, 0x00},
+			want: apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Ne: 65536},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := apdu.ParseOptions{LeZeroMeansZero: true}.ParseCapdu(tt.c)
+			if err != nil {
+				t.Fatalf("ParseCapdu() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseCapdu() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCapduFiveByteBoundary(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		c        string
+		want     apdu.Capdu
+		wantCase int
+		wantErr  bool
+	}{
+		{
+			name:     "header and Le",
+			c:        "00A4040005",
+			want:     apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, Ne: 5},
+			wantCase: 2,
+		},
+		{
+			name:     "header and Le 00",
+			c:        "00A4040000",
+			want:     apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, Ne: 256},
+			wantCase: 2,
+		},
+		{
+			name:     "header, Lc and data",
+			c:        "00A40400050102030405",
+			want:     apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, Data: []byte{0x01, 0x02, 0x03, 0x04, 0x05}},
+			wantCase: 3,
+		},
+		{
+			name:     "header, Lc, data and Le",
+			c:        "00A4040005010203040500",
+			want:     apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, Data: []byte{0x01, 0x02, 0x03, 0x04, 0x05}, Ne: 256},
+			wantCase: 4,
+		},
+		{
+			name:     "header, Lc and single data byte",
+			c:        "00A404000101",
+			want:     apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, Data: []byte{0x01}},
+			wantCase: 3,
+		},
+		{
+			name:    "error: header and Lc without enough data",
+			c:       "00A404000501",
 			wantErr: true,
 		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			b, err := hex.DecodeString(tt.c)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := apdu.ParseCapdu(b)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseCapdu() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseCapdu() got = %v, want %v", got, tt.want)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if c := got.Case(); c != tt.wantCase {
+				t.Errorf("Case() got = %d, want %d", c, tt.wantCase)
+			}
+		})
+	}
+}
+
+func TestParseOptions_AllowStandardLcExtendedLe(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		c          []byte
+		want       apdu.Capdu
+		wantBytes  []byte
+		wantErr    bool
+		wantStrict bool
+	}{
+		{
+			name:      "mixed encoding",
+			c:         []byte{0x00, 0xB0, 0x00, 0x00, 0x02, 0x01, 0x02, 0x01, 0x2C},
+			want:      apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Data: []byte{0x01, 0x02}, Ne: 300},
+			wantBytes: []byte{0x00, 0xB0, 0x00, 0x00, 0x00, 0x00, 0x02, 0x01, 0x02, 0x01, 0x2C},
+		},
 		{
-			name:    "error: invalid characters",
-			args:    args{"s:00010203GG"},
+			name:      "mixed encoding Ne fits standard",
+			c:         []byte{0x00, 0xA4, 0x04, 0x00, 0x02, 0x01, 0x02, 0x00, 0x10},
+			want:      apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, Data: []byte{0x01, 0x02}, Ne: 16},
+			wantBytes: []byte{0x00, 0xA4, 0x04, 0x00, 0x02, 0x01, 0x02, 0x10},
+		},
+		{
+			name:      "mixed encoding Le equal zero",
+			c:         []byte{0x00, 0xB0, 0x00, 0x00, 0x01, 0x01, 0x00, 0x00},
+			want:      apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Data: []byte{0x01}, Ne: 65536},
+			wantBytes: []byte{0x00, 0xB0, 0x00, 0x00, 0x00, 0x00, 0x01, 0x01, 0x00, 0x00},
+		},
+		{
+			name:       "standard Case 4 unaffected",
+			c:          []byte{0x00, 0xB0, 0x00, 0x00, 0x02, 0x01, 0x02, 0x10},
+			want:       apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Data: []byte{0x01, 0x02}, Ne: 16},
+			wantBytes:  []byte{0x00, 0xB0, 0x00, 0x00, 0x02, 0x01, 0x02, 0x10},
+			wantStrict: true,
+		},
+		{
+			name:    "error: Lc still invalid",
+			c:       []byte{0x00, 0xB0, 0x00, 0x00, 0x01, 0x01, 0x02, 0x03, 0x04},
 			wantErr: true,
 		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := apdu.ParseOptions{AllowStandardLcExtendedLe: true}.ParseCapdu(tt.c)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseCapdu() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseCapdu() got = %v, want %v", got, tt.want)
+			}
+			if tt.wantBytes != nil {
+				if b, err := got.Bytes(); err != nil || !bytes.Equal(b, tt.wantBytes) {
+					t.Errorf("Bytes() = %X, %v, want %X", b, err, tt.wantBytes)
+				}
+			}
+
+			if _, err = apdu.ParseCapdu(tt.c); (err == nil) != tt.wantStrict {
+				t.Errorf("strict ParseCapdu() error = %v, wantStrict %v", err, tt.wantStrict)
+			}
+		})
+	}
+}
+
+func TestParseOptions_AllowExtendedSingleZeroLe(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		c          []byte
+		want       apdu.Capdu
+		wantErr    bool
+		wantStrict bool
+	}{
+		{
+			name: "single zero Le after extended data",
+			c:    []byte{0x00, 0xB0, 0x00, 0x00, 0x00, 0x00, 0x02, 0x01, 0x02, 0x00},
+			want: apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Data: []byte{0x01, 0x02}, Ne: 65536},
+		},
 		{
-			name:    "standard length CASE 1",
-			args:    args{s: "00A40401"},
-			want:    apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x01, Ne: 0},
-			wantErr: false,
+			name:       "extended Case 4 unaffected",
+			c:          []byte{0x00, 0xB0, 0x00, 0x00, 0x00, 0x00, 0x02, 0x01, 0x02, 0x01, 0x2C},
+			want:       apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Data: []byte{0x01, 0x02}, Ne: 300},
+			wantStrict: true,
+		},
+		{
+			name:       "extended Case 3 unaffected",
+			c:          []byte{0x00, 0xB0, 0x00, 0x00, 0x00, 0x00, 0x02, 0x01, 0x02},
+			want:       apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Data: []byte{0x01, 0x02}},
+			wantStrict: true,
+		},
+		{
+			name:    "error: single non-zero Le",
+			c:       []byte{0x00, 0xB0, 0x00, 0x00, 0x00, 0x00, 0x02, 0x01, 0x02, 0x10},
+			wantErr: true,
 		},
 	}
 
@@ -164,195 +514,2265 @@ func TestParseCapduHexString(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
 
-			got, err := apdu.ParseCapduHexString(tt.args.s)
+			got, err := apdu.ParseOptions{AllowExtendedSingleZeroLe: true}.ParseCapdu(tt.c)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("ParseCapduHexString() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("ParseCapdu() error = %v, wantErr %v", err, tt.wantErr)
 
 				return
 			}
 			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("ParseCapduHexString() got = %v, want %v", got, tt.want)
+				t.Errorf("ParseCapdu() got = %v, want %v", got, tt.want)
+			}
+
+			if _, err = apdu.ParseCapdu(tt.c); (err == nil) != tt.wantStrict {
+				t.Errorf("strict ParseCapdu() error = %v, wantStrict %v", err, tt.wantStrict)
 			}
 		})
 	}
 }
 
-func TestCapdu_Bytes(t *testing.T) {
+func TestEncodeOptions_LeZeroMeansZero(t *testing.T) {
 	t.Parallel()
 
-	extendedData := make([]byte, 65535)
-	for i := range extendedData {
-		extendedData[i] = 0xFF
+	tests := []struct {
+		name  string
+		capdu apdu.Capdu
+		want  []byte
+	}{
+		{
+			name:  "Ne 256 promoted to extended length",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Ne: 256},
+			want:  []byte{0x00, 0xB0, 0x00, 0x00, 0x00, 0x01, 0x00},
+		},
+		{
+			name:  "Ne 255 standard length",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Ne: 255},
+			want:  []byte{0x00, 0xB0, 0x00, 0x00, 0xFF},
+		},
+		{
+			name:  "Ne 0 without Le",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, Data: []byte{0x01, 0x02}},
+			want:  []byte{0x00, 0xA4, 0x04, 0x00, 0x02, 0x01, 0x02},
+		},
+		{
+			name:  "Case 2 zero Le",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, ZeroLe: true},
+			want:  []byte{0x00, 0xB0, 0x00, 0x00, 0x00},
+		},
+		{
+			name:  "Case 4 zero Le",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, ZeroLe: true, Data: []byte{0xAA}},
+			want:  []byte{0x00, 0xA4, 0x04, 0x00, 0x01, 0xAA, 0x00},
+		},
 	}
 
-	tooExtendedData := make([]byte, 65536)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := apdu.EncodeOptions{LeZeroMeansZero: true}.Bytes(tt.capdu)
+			if err != nil {
+				t.Fatalf("Bytes() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Bytes() got = %X, want %X", got, tt.want)
+			}
+
+			parsed, err := apdu.ParseOptions{LeZeroMeansZero: true}.ParseCapdu(got)
+			if err != nil {
+				t.Fatalf("ParseCapdu() error = %v", err)
+			}
+			if !parsed.Equal(tt.capdu) {
+				t.Errorf("ParseCapdu() got = %#v, want %#v", parsed, tt.capdu)
+			}
+		})
+	}
+}
+
+func TestLeZeroMeansZeroRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	if _, err := (apdu.EncodeOptions{LeZeroMeansZero: true}).Bytes(apdu.Capdu{INS: 0xD6, ZeroLe: true, Data: make([]byte, 256)}); err == nil {
+		t.Error("Bytes() expected error for zero Le with extended length data")
+	}
+
+	for _, s := range []string{"00B0000000", "00A4040001AA00", "00B0000005", "00A4040001AA", "00B00000"} {
+		t.Run(s, func(t *testing.T) {
+			t.Parallel()
+
+			b, err := hex.DecodeString(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			c, err := apdu.ParseOptions{LeZeroMeansZero: true}.ParseCapdu(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := apdu.EncodeOptions{LeZeroMeansZero: true}.Bytes(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, b) {
+				t.Errorf("Bytes() got = %X, want %X", got, b)
+			}
+		})
+	}
+}
+
+func TestParseCapduHexString(t *testing.T) {
+	t.Parallel()
+
+	type args struct {
+		s string
+	}
+
+	tests := []struct {
+		name    string
+		args    args
+		want    apdu.Capdu
+		wantErr bool
+	}{
+		{
+			name:    "error: uneven number bytes",
+			args:    args{s: "000102030"},
+			wantErr: true,
+		},
+		{
+			name:    "error: invalid length",
+			args:    args{s: "000102"},
+			wantErr: true,
+		},
+		{
+			name:    "error: invalid characters",
+			args:    args{"s:00010203GG"},
+			wantErr: true,
+		},
+		{
+			name:    "standard length CASE 1",
+			args:    args{s: "00A40401"},
+			want:    apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x01, Ne: 0},
+			wantErr: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := apdu.ParseCapduHexString(tt.args.s)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseCapduHexString() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseCapduHexString() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCapdu_Bytes(t *testing.T) {
+	t.Parallel()
+
+	extendedData := make([]byte, 65535)
+	for i := range extendedData {
+		extendedData[i] = 0xFF
+	}
+
+	tooExtendedData := make([]byte, 65536)
+
+	type fields struct {
+		CLA  byte
+		INS  byte
+		P1   byte
+		P2   byte
+		Data []byte
+		Ne   int
+	}
+
+	tests := []struct {
+		name    string
+		fields  fields
+		want    []byte
+		wantErr bool
+	}{
+		{
+			name:    "standard length CASE 1",
+			fields:  fields{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x01, Ne: 0},
+			want:    []byte{0x00, 0xA4, 0x04, 0x01},
+			wantErr: false,
+		},
+		{
+			name:    "standard length CASE 2 Le unequal zero",
+			fields:  fields{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x01, Ne: 255},
+			want:    []byte{0x00, 0xA4, 0x04, 0x01, 0xFF},
+			wantErr: false,
+		},
+		{
+			name:    "standard length CASE 2 Le equal zero",
+			fields:  fields{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x01, Ne: 256},
+			want:    []byte{0x00, 0xA4, 0x04, 0x01, 0x00},
+			wantErr: false,
+		},
+		{
+			name:    "standard length CASE 3",
+			fields:  fields{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x01, Data: []byte{0x01, 0x02, 0x3}, Ne: 0},
+			want:    []byte{0x00, 0xA4, 0x04, 0x01, 0x03, 0x01, 0x02, 0x03},
+			wantErr: false,
+		},
+		{
+			name:    "standard length CASE 4",
+			fields:  fields{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x01, Data: []byte{0x01, 0x02}, Ne: 3},
+			want:    []byte{0x00, 0xA4, 0x04, 0x01, 0x02, 0x01, 0x02, 0x03},
+			wantErr: false,
+		},
+		{
+			name:    "extended length CASE 2 Le unequal zero",
+			fields:  fields{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x01, Ne: 65535},
+			want:    []byte{0x00, 0xA4, 0x04, 0x01, 0x00, 0xFF, 0xFF},
+			wantErr: false,
+		},
+		{
+			name:    "extended length CASE 2 Ne 257",
+			fields:  fields{CLA: 0x00, INS: 0xCA, P1: 0x00, P2: 0x00, Ne: 257},
+			want:    []byte{0x00, 0xCA, 0x00, 0x00, 0x00, 0x01, 0x01},
+			wantErr: false,
+		},
+		{
+			name:    "extended length CASE 2 Ne 300",
+			fields:  fields{CLA: 0x00, INS: 0xCA, P1: 0x00, P2: 0x00, Ne: 300},
+			want:    []byte{0x00, 0xCA, 0x00, 0x00, 0x00, 0x01, 0x2C},
+			wantErr: false,
+		},
+		{
+			name:    "extended length CASE 4 standard data Ne 300",
+			fields:  fields{CLA: 0x00, INS: 0xCA, P1: 0x00, P2: 0x00, Data: []byte{0x01, 0x02}, Ne: 300},
+			want:    []byte{0x00, 0xCA, 0x00, 0x00, 0x00, 0x00, 0x02, 0x01, 0x02, 0x01, 0x2C},
+			wantErr: false,
+		},
+		{
+			name:    "extended length CASE 2 Le equal zero",
+			fields:  fields{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x01, Ne: 65536},
+			want:    []byte{0x00, 0xA4, 0x04, 0x01, 0x00, 0x00, 0x00},
+			wantErr: false,
+		},
+		{
+			name:    "error: ne invalid CASE 2",
+			fields:  fields{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x01, Ne: 65537},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "extended length CASE 3",
+			fields:  fields{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x01, Data: extendedData, Ne: 0},
+			want:    append([]byte{0x00, 0xA4, 0x04, 0x01, 0x00, 0xFF, 0xFF}, extendedData...),
+			wantErr: false,
+		},
+		{
+			name:    "error: invalid length CASE 3",
+			fields:  fields{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x01, Data: tooExtendedData, Ne: 0},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "extended length CASE 4 Le unequal zero",
+			fields:  fields{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x01, Data: extendedData, Ne: 65535},
+			want:    append(append([]byte{0x00, 0xA4, 0x04, 0x01, 0x00, 0xFF, 0xFF}, extendedData...), []byte{0xFF, 0xFF}...),
+			wantErr: false,
+		},
+		{
+			name:    "extended length CASE 4 Le equal zero",
+			fields:  fields{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x01, Data: extendedData, Ne: 65536},
+			want:    append(append([]byte{0x00, 0xA4, 0x04, 0x01, 0x00, 0xFF, 0xFF}, extendedData...), []byte{0x00, 0x00}...),
+			wantErr: false,
+		},
+		{
+			name:    "error: data extended length CASE 4",
+			fields:  fields{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x01, Data: tooExtendedData, Ne: 255},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "error: ne invalid length CASE 4",
+			fields:  fields{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x01, Data: tooExtendedData, Ne: 65537},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := apdu.Capdu{
+				CLA:  tt.fields.CLA,
+				INS:  tt.fields.INS,
+				P1:   tt.fields.P1,
+				P2:   tt.fields.P2,
+				Data: tt.fields.Data,
+				Ne:   tt.fields.Ne,
+			}
+			got, err := c.Bytes()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Bytes() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Bytes() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCapdu_EncodeInto(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		capdu   apdu.Capdu
+		bufLen  int
+		wantErr bool
+	}{
+		{
+			name:   "Case 1",
+			capdu:  apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00},
+			bufLen: 4,
+		},
+		{
+			name:   "Case 4 standard length larger buffer",
+			capdu:  apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, Data: []byte{0x01, 0x02}, Ne: 256},
+			bufLen: 16,
+		},
+		{
+			name:   "Case 2 extended length",
+			capdu:  apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Ne: 65536},
+			bufLen: 7,
+		},
+		{
+			name:   "Case 4 extended length",
+			capdu:  apdu.Capdu{CLA: 0x00, INS: 0xDA, P1: 0x00, P2: 0x00, Data: make([]byte, 300), Ne: 256},
+			bufLen: 309,
+		},
+		{
+			name:    "error: buffer too small",
+			capdu:   apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, Data: []byte{0x01, 0x02}, Ne: 256},
+			bufLen:  7,
+			wantErr: true,
+		},
+		{
+			name:    "error: invalid Capdu",
+			capdu:   apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Ne: 65537},
+			bufLen:  16,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			buf := make([]byte, tt.bufLen)
+			n, err := tt.capdu.EncodeInto(buf)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("EncodeInto() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+
+			want, err := tt.capdu.Bytes()
+			if err != nil {
+				t.Fatalf("Bytes() error = %v", err)
+			}
+			if !reflect.DeepEqual(buf[:n], want) {
+				t.Errorf("EncodeInto() got = %X, want %X", buf[:n], want)
+			}
+		})
+	}
+}
+
+func TestCapdu_EncodeIntoAllocs(t *testing.T) {
+	c := apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, Data: []byte{0x01, 0x02}, Ne: 256}
+	buf := make([]byte, 16)
+
+	if allocs := testing.AllocsPerRun(100, func() { _, _ = c.EncodeInto(buf) }); allocs != 0 {
+		t.Errorf("EncodeInto() allocs = %v, want 0", allocs)
+	}
+}
+
+func TestPromoteToExtended(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		b       string
+		want    string
+		wantErr bool
+	}{
+		{name: "Case 1 unchanged", b: "00A40400", want: "00A40400"},
+		{name: "Case 2", b: "00B0000010", want: "00B00000000010"},
+		{name: "Case 2 Le 00", b: "00B0000000", want: "00B00000000100"},
+		{name: "Case 3", b: "00A4000C023F00", want: "00A4000C0000023F00"},
+		{name: "Case 4 Le 00", b: "00A4040002A00100", want: "00A40400000002A0010100"},
+		{name: "already extended", b: "00B00000000000", want: "00B00000000000"},
+		{name: "error: invalid command", b: "00A40400053F00", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			b, err := hex.DecodeString(tt.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := apdu.PromoteToExtended(b)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("PromoteToExtended() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+			if s := strings.ToUpper(hex.EncodeToString(got)); !tt.wantErr && s != tt.want {
+				t.Errorf("PromoteToExtended() got = %s, want %s", s, tt.want)
+			}
+		})
+	}
+}
+
+func TestDemoteToStandard(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		b       string
+		want    string
+		wantErr bool
+	}{
+		{name: "Case 1 unchanged", b: "00A40400", want: "00A40400"},
+		{name: "Case 2", b: "00B00000000010", want: "00B0000010"},
+		{name: "Case 2 Le 0100", b: "00B00000000100", want: "00B0000000"},
+		{name: "Case 4", b: "00A40400000002A0010100", want: "00A4040002A00100"},
+		{name: "already standard", b: "00A4000C023F00", want: "00A4000C023F00"},
+		{name: "error: Ne exceeds standard length", b: "00B00000000000", wantErr: true},
+		{name: "error: invalid command", b: "00A40400053F00", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			b, err := hex.DecodeString(tt.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := apdu.DemoteToStandard(b)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("DemoteToStandard() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+			if s := strings.ToUpper(hex.EncodeToString(got)); !tt.wantErr && s != tt.want {
+				t.Errorf("DemoteToStandard() got = %s, want %s", s, tt.want)
+			}
+		})
+	}
+}
+
+func TestCapdu_FitsIFS(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		capdu apdu.Capdu
+		ifs   int
+		want  bool
+	}{
+		{
+			name:  "fits",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, Data: []byte{0x01, 0x02}, Ne: 256},
+			ifs:   8,
+			want:  true,
+		},
+		{
+			name:  "exceeds by one byte",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, Data: []byte{0x01, 0x02}, Ne: 256},
+			ifs:   7,
+		},
+		{
+			name:  "extended length exceeds default IFS",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xDA, P1: 0x00, P2: 0x00, Data: make([]byte, 256)},
+			ifs:   254,
+		},
+		{
+			name:  "invalid Capdu",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Ne: 65537},
+			ifs:   254,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tt.capdu.FitsIFS(tt.ifs); got != tt.want {
+				t.Errorf("FitsIFS() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCapdu_Len(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		capdu   apdu.Capdu
+		wantErr bool
+	}{
+		{
+			name:  "Case 1",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00},
+		},
+		{
+			name:  "Case 4 standard length",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, Data: []byte{0x01, 0x02}, Ne: 256},
+		},
+		{
+			name:  "Case 2 extended length",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Ne: 65536},
+		},
+		{
+			name:  "Case 3 extended length",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xDA, P1: 0x00, P2: 0x00, Data: make([]byte, 256)},
+		},
+		{
+			name:    "error: invalid Capdu",
+			capdu:   apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Ne: 65537},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := tt.capdu.Len()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Len() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+
+			b, err := tt.capdu.Bytes()
+			if err != nil {
+				t.Fatalf("Bytes() error = %v", err)
+			}
+			if got != len(b) {
+				t.Errorf("Len() got = %d, want %d", got, len(b))
+			}
+		})
+	}
+}
+
+func TestEncodeCapduBatch(t *testing.T) {
+	t.Parallel()
+
+	cmds := []apdu.Capdu{
+		{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, Data: []byte{0xA0, 0x00}, Ne: 256},
+		{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Ne: 300},
+		{CLA: 0x00, INS: 0x84, P1: 0x00, P2: 0x00},
+	}
+
+	got, offsets, err := apdu.EncodeCapduBatch(cmds)
+	if err != nil {
+		t.Fatalf("EncodeCapduBatch() error = %v", err)
+	}
+
+	want := []byte{0x00, 0xA4, 0x04, 0x00, 0x02, 0xA0, 0x00, 0x00, 0x00, 0xB0, 0x00, 0x00, 0x00, 0x01, 0x2C, 0x00, 0x84, 0x00, 0x00}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("EncodeCapduBatch() got = %X, want %X", got, want)
+	}
+	if !reflect.DeepEqual(offsets, []int{0, 8, 15}) {
+		t.Errorf("EncodeCapduBatch() offsets = %v, want [0 8 15]", offsets)
+	}
+	if cap(got) != len(got) {
+		t.Errorf("EncodeCapduBatch() cap = %d, want %d", cap(got), len(got))
+	}
+
+	cmds = append(cmds, apdu.Capdu{Ne: 65537})
+	if _, _, err = apdu.EncodeCapduBatch(cmds); err == nil || !strings.Contains(err.Error(), "command 3") {
+		t.Errorf("EncodeCapduBatch() error = %v, want error for command 3", err)
+	}
+}
+
+func TestCapdu_Validate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		capdu   apdu.Capdu
+		wantErr bool
+	}{
+		{
+			name:  "valid without ExpectedNc",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, Data: []byte{0x01, 0x02}},
+		},
+		{
+			name:  "valid with matching ExpectedNc",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, Data: []byte{0x01, 0x02}, ExpectedNc: 2},
+		},
+		{
+			name:    "error: ExpectedNc larger than data",
+			capdu:   apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, Data: []byte{0x01, 0x02}, ExpectedNc: 3},
+			wantErr: true,
+		},
+		{
+			name:    "error: ExpectedNc without data",
+			capdu:   apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, ExpectedNc: 1},
+			wantErr: true,
+		},
+		{
+			name:    "error: data too long",
+			capdu:   apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, Data: make([]byte, 65536)},
+			wantErr: true,
+		},
+		{
+			name:    "error: ne too big",
+			capdu:   apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, Ne: 65537},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if err := tt.capdu.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if _, err := tt.capdu.Bytes(); (err != nil) != tt.wantErr {
+				t.Errorf("Bytes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCapdu_Transmittable(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name                 string
+		capdu                apdu.Capdu
+		extendedSupported    bool
+		wantRequiresExtended bool
+		wantErr              bool
+	}{
+		{
+			name:  "standard without extended support",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xB0, Data: make([]byte, 255), Ne: 256},
+		},
+		{
+			name:                 "extended data with extended support",
+			capdu:                apdu.Capdu{CLA: 0x00, INS: 0xD6, Data: make([]byte, 256)},
+			extendedSupported:    true,
+			wantRequiresExtended: true,
+		},
+		{
+			name:                 "error: extended data without extended support",
+			capdu:                apdu.Capdu{CLA: 0x00, INS: 0xD6, Data: make([]byte, 256)},
+			wantRequiresExtended: true,
+			wantErr:              true,
+		},
+		{
+			name:                 "error: extended Ne without extended support",
+			capdu:                apdu.Capdu{CLA: 0x00, INS: 0xB0, Ne: 257},
+			wantRequiresExtended: true,
+			wantErr:              true,
+		},
+		{
+			name:                 "error: invalid Capdu",
+			capdu:                apdu.Capdu{CLA: 0x00, INS: 0xB0, Ne: 65537},
+			extendedSupported:    true,
+			wantRequiresExtended: true,
+			wantErr:              true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tt.capdu.RequiresExtended(); got != tt.wantRequiresExtended {
+				t.Errorf("RequiresExtended() = %v, want %v", got, tt.wantRequiresExtended)
+			}
+			if err := tt.capdu.Transmittable(tt.extendedSupported); (err != nil) != tt.wantErr {
+				t.Errorf("Transmittable() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCapdu_EncodedLe(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		capdu   apdu.Capdu
+		want    []byte
+		wantErr bool
+	}{
+		{
+			name:  "Ne 0",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xB0},
+			want:  []byte{},
+		},
+		{
+			name:  "Ne 0 with data",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xD6, Data: []byte{0x01}},
+			want:  []byte{},
+		},
+		{
+			name:  "Ne 1",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xB0, Ne: 1},
+			want:  []byte{0x01},
+		},
+		{
+			name:  "Ne 256",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xB0, Ne: 256},
+			want:  []byte{0x00},
+		},
+		{
+			name:  "Ne 257",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xB0, Ne: 257},
+			want:  []byte{0x01, 0x01},
+		},
+		{
+			name:  "Ne 65536",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xB0, Ne: 65536},
+			want:  []byte{0x00, 0x00},
+		},
+		{
+			name:  "Ne 1 with extended data",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xA4, Data: make([]byte, 256), Ne: 1},
+			want:  []byte{0x00, 0x01},
+		},
+		{
+			name:    "error: Ne 65537",
+			capdu:   apdu.Capdu{CLA: 0x00, INS: 0xB0, Ne: 65537},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := tt.capdu.EncodedLe()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("EncodedLe() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("EncodedLe() got = %X, want %X", got, tt.want)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			b, err := tt.capdu.Bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.HasSuffix(b, got) {
+				t.Errorf("Bytes() = %X does not end with EncodedLe() = %X", b, got)
+			}
+		})
+	}
+}
+
+func TestCapdu_Nc(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		capdu apdu.Capdu
+		want  int
+	}{
+		{
+			name:  "no data",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xB0, Ne: 256},
+			want:  0,
+		},
+		{
+			name:  "with data",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xA4, Data: []byte{0x3F, 0x00}},
+			want:  2,
+		},
+		{
+			name:  "ExpectedNc ignored",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xA4, Data: []byte{0x3F}, ExpectedNc: 2},
+			want:  1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tt.capdu.Nc(); got != tt.want {
+				t.Errorf("Nc() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCapdu_NeFitsStandard(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		capdu apdu.Capdu
+		want  bool
+	}{
+		{
+			name:  "no Ne",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xD6, Data: make([]byte, 300)},
+			want:  true,
+		},
+		{
+			name:  "Ne 256 with extended data",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xA4, Data: make([]byte, 300), Ne: 256},
+			want:  true,
+		},
+		{
+			name:  "Ne 257",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xB0, Ne: 257},
+		},
+		{
+			name:  "Ne 65536",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xB0, Ne: 65536},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tt.capdu.NeFitsStandard(); got != tt.want {
+				t.Errorf("NeFitsStandard() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCapdu_IsExtendedLength(t *testing.T) {
+	t.Parallel()
+
+	extendedData := make([]byte, 256)
+	for i := range extendedData {
+		extendedData[i] = 0xFF
+	}
+
+	standardData := make([]byte, 255)
+	for i := range standardData {
+		standardData[i] = 0xFF
+	}
+
+	type fields struct {
+		CLA  byte
+		INS  byte
+		P1   byte
+		P2   byte
+		Data []byte
+		Ne   int
+	}
+
+	tests := []struct {
+		name   string
+		fields fields
+		want   bool
+	}{
+		{
+			name:   "extended length ne",
+			fields: fields{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x01, Ne: 257},
+			want:   true,
+		},
+		{
+			name:   "extended length data",
+			fields: fields{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x01, Data: extendedData, Ne: 256},
+			want:   true,
+		},
+		{
+			name:   "standard length",
+			fields: fields{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x01, Data: standardData, Ne: 256},
+			want:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := apdu.Capdu{
+				CLA:  tt.fields.CLA,
+				INS:  tt.fields.INS,
+				P1:   tt.fields.P1,
+				P2:   tt.fields.P2,
+				Data: tt.fields.Data,
+				Ne:   tt.fields.Ne,
+			}
+			if got := c.IsExtendedLength(); got != tt.want {
+				t.Errorf("IsExtendedLength() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCapdu_String(t *testing.T) {
+	t.Parallel()
+
+	type fields struct {
+		CLA  byte
+		INS  byte
+		P1   byte
+		P2   byte
+		Data []byte
+		Ne   int
+	}
+
+	tests := []struct {
+		name    string
+		fields  fields
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "to string",
+			fields:  fields{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x01, Data: []byte{0x01, 0x02}, Ne: 3},
+			want:    "00A4040102010203",
+			wantErr: false,
+		},
+		{
+			name:    "error: invalid ne",
+			fields:  fields{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x01, Data: []byte{0x01, 0x02}, Ne: 65537},
+			want:    "",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := apdu.Capdu{
+				CLA:  tt.fields.CLA,
+				INS:  tt.fields.INS,
+				P1:   tt.fields.P1,
+				P2:   tt.fields.P2,
+				Data: tt.fields.Data,
+				Ne:   tt.fields.Ne,
+			}
+			got, err := c.String()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("String() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+			if got != tt.want {
+				t.Errorf("String() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCapdu_GoString(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		capdu apdu.Capdu
+		want  string
+	}{
+		{
+			name:  "without data",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x81, P2: 0x00, Ne: 256},
+			want:  `apdu.Capdu{CLA:0x00, INS:0xB0, P1:0x81, P2:0x00, Data:nil, Ne:256}`,
+		},
+		{
+			name:  "with data, ExpectedNc and label",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x00, P2: 0x0C, Data: []byte{0x3F, 0x00}, ExpectedNc: 2, Label: "Select MF"},
+			want:  `apdu.Capdu{CLA:0x00, INS:0xA4, P1:0x00, P2:0x0C, Data:hex"3F00", Ne:0, ExpectedNc:2, Label:"Select MF"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := fmt.Sprintf("%#v", tt.capdu); got != tt.want {
+				t.Errorf("GoString() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCapdu_StringAllocs is not parallel as allocations of other tests would be counted.
+func TestCapdu_StringAllocs(t *testing.T) {
+	standard := apdu.Capdu{CLA: 0x00, INS: 0xAA, P1: 0xBB, P2: 0xCC, Data: []byte{0x01, 0x02, 0x03, 0x04, 0x05}, Ne: 0xFF}
+	extended := apdu.Capdu{CLA: 0x00, INS: 0xAA, P1: 0xBB, P2: 0xCC, Data: make([]byte, 300), Ne: 0x1000}
+
+	for _, c := range []apdu.Capdu{standard, extended} {
+		if allocs := testing.AllocsPerRun(100, func() { _, _ = c.String() }); allocs != 1 {
+			t.Errorf("String() allocs = %v, want 1", allocs)
+		}
+	}
+}
+
+func TestCapdu_StringLower(t *testing.T) {
+	t.Parallel()
+
+	got, err := apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x01, Data: []byte{0xAB, 0xCD}, Ne: 3}.StringLower()
+	if err != nil {
+		t.Fatalf("StringLower() error = %v", err)
+	}
+	if want := "00a4040102abcd03"; got != want {
+		t.Errorf("StringLower() got = %v, want %v", got, want)
+	}
+
+	if _, err = (apdu.Capdu{CLA: 0x00, INS: 0xA4, Ne: 65537}).StringLower(); err == nil {
+		t.Error("StringLower() expected error")
+	}
+}
+
+func TestCapdu_AppendHex(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		dst     []byte
+		capdu   apdu.Capdu
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "nil dst",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x01, Data: []byte{0xAB, 0xCD}, Ne: 3},
+			want:  "00A4040102ABCD03",
+		},
+		{
+			name:  "append to prefix",
+			dst:   []byte("> "),
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x01, Data: []byte{0xAB, 0xCD}, Ne: 3},
+			want:  "> 00A4040102ABCD03",
+		},
+		{
+			name:  "extended length",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Ne: 65536},
+			want:  "00B00000000000",
+		},
+		{
+			name:    "error: invalid ne",
+			dst:     []byte("> "),
+			capdu:   apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x01, Ne: 65537},
+			want:    "> ",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := tt.capdu.AppendHex(tt.dst)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("AppendHex() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("AppendHex() got = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCapdu_GetResponse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		capdu apdu.Capdu
+		ne    int
+		want  string
+	}{
+		{
+			name:  "basic channel",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, Data: []byte{0xA0, 0x00}},
+			ne:    0x10,
+			want:  "00C0000010",
+		},
+		{
+			name:  "channel 2",
+			capdu: apdu.Capdu{CLA: 0x02, INS: 0xA4, P1: 0x04, P2: 0x00, Data: []byte{0xA0, 0x00}},
+			ne:    0x20,
+			want:  "02C0000020",
+		},
+		{
+			name:  "channel 2 with secure messaging and proprietary class",
+			capdu: apdu.Capdu{CLA: 0x8E, INS: 0xCA, P1: 0x00, P2: 0x66, Ne: 256},
+			ne:    256,
+			want:  "02C0000000",
+		},
+		{
+			name:  "channel 5",
+			capdu: apdu.Capdu{CLA: 0x61, INS: 0xB0, P1: 0x00, P2: 0x00, Ne: 256},
+			ne:    0x08,
+			want:  "41C0000008",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := tt.capdu.GetResponse(tt.ne).String()
+			if err != nil {
+				t.Fatalf("String() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("GetResponse() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCapduBody(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		header [4]byte
+		data   []byte
+		ne     int
+		want   apdu.Capdu
+	}{
+		{
+			name:   "header only",
+			header: [4]byte{0x00, 0x84, 0x00, 0x00},
+			data:   []byte{},
+			want:   apdu.Capdu{CLA: 0x00, INS: 0x84, P1: 0x00, P2: 0x00},
+		},
+		{
+			name:   "data without Lc and Ne",
+			header: [4]byte{0x00, 0xA4, 0x04, 0x00},
+			data:   []byte{0xA0, 0x00, 0x00},
+			ne:     256,
+			want:   apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, Data: []byte{0xA0, 0x00, 0x00}, Ne: 256},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := apdu.ParseCapduBody(tt.header, tt.data, tt.ne); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseCapduBody() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCapduHexStringLenient(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		s       string
+		want    apdu.Capdu
+		wantErr bool
+	}{
+		{
+			name: "strict form",
+			s:    "00A4000C023F00",
+			want: apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x00, P2: 0x0C, Data: []byte{0x3F, 0x00}},
+		},
+		{
+			name: "spaces, colons and 0x prefixes",
+			s:    " 00 a4:00:0c 0x02 0x3F00 ",
+			want: apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x00, P2: 0x0C, Data: []byte{0x3F, 0x00}},
+		},
+		{
+			name:    "error: invalid characters",
+			s:       "00 A4 00 GG",
+			wantErr: true,
+		},
+		{
+			name:    "error: too short",
+			s:       "00 A4",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := apdu.ParseCapduHexStringLenient(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseCapduHexStringLenient() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseCapduHexStringLenient() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCapduCString(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		s       string
+		want    apdu.Capdu
+		wantErr bool
+	}{
+		{
+			name: "null-terminated",
+			s:    "00B0000000\x00",
+			want: apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Ne: 256},
+		},
+		{
+			name: "trailing garbage after NUL",
+			s:    "00 B0 00 00 00\x00\xffgarbage\x00",
+			want: apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Ne: 256},
+		},
+		{
+			name: "without NUL",
+			s:    "00B0000000",
+			want: apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Ne: 256},
+		},
+		{
+			name:    "error: no hex before NUL",
+			s:       "\x0000B0000000",
+			wantErr: true,
+		},
+		{
+			name:    "error: only whitespace before NUL",
+			s:       " \x00",
+			wantErr: true,
+		},
+		{
+			name:    "error: invalid hex before NUL",
+			s:       "00B0000Z00\x00",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := apdu.ParseCapduCString(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseCapduCString() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseCapduCString() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCapduHexStringInto(t *testing.T) {
+	t.Parallel()
+
+	buf := make([]byte, 0, 8)
+
+	c, buf, err := apdu.ParseCapduHexStringInto(buf, "00A4040C023F00")
+	if err != nil {
+		t.Fatalf("ParseCapduHexStringInto() error = %v", err)
+	}
+	if want := (apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x0C, Data: []byte{0x3F, 0x00}}); !reflect.DeepEqual(c, want) {
+		t.Errorf("ParseCapduHexStringInto() got = %v, want %v", c, want)
+	}
+	if cap(buf) != 8 {
+		t.Errorf("ParseCapduHexStringInto() reallocated buffer with sufficient capacity, cap = %d", cap(buf))
+	}
+
+	c, buf, err = apdu.ParseCapduHexStringInto(buf, "00d6000009010203040506070809")
+	if err != nil {
+		t.Fatalf("ParseCapduHexStringInto() error = %v", err)
+	}
+	if len(buf) != 14 || len(c.Data) != 9 || c.Data[8] != 0x09 {
+		t.Errorf("ParseCapduHexStringInto() got = %v with buffer of length %d", c, len(buf))
+	}
+
+	for _, s := range []string{"00A4040", "00A404", "00A4040G", "00A4040C053F00"} {
+		if _, _, err = apdu.ParseCapduHexStringInto(buf, s); err == nil {
+			t.Errorf("ParseCapduHexStringInto(%q) expected error", s)
+		}
+	}
+}
+
+func TestParseCapduDetailed(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		c        string
+		wantLcLe apdu.LcLe
+		wantErr  bool
+	}{
+		{
+			name: "Case 1",
+			c:    "00A40400",
+		},
+		{
+			name:     "Case 2 standard length",
+			c:        "00A4040000",
+			wantLcLe: apdu.LcLe{LeBytes: []byte{0x00}},
+		},
+		{
+			name:     "Case 2 extended length",
+			c:        "00A40400000100",
+			wantLcLe: apdu.LcLe{LeBytes: []byte{0x00, 0x01, 0x00}, Extended: true},
+		},
+		{
+			name:     "Case 2 HID hack",
+			c:        "00A404000000",
+			wantLcLe: apdu.LcLe{LcBytes: []byte{0x00}, LeBytes: []byte{0x00}},
+		},
+		{
+			name:     "Case 3 standard length",
+			c:        "00A40400020102",
+			wantLcLe: apdu.LcLe{LcBytes: []byte{0x02}},
+		},
+		{
+			name:     "Case 4 standard length",
+			c:        "00A4040002010200",
+			wantLcLe: apdu.LcLe{LcBytes: []byte{0x02}, LeBytes: []byte{0x00}},
+		},
+		{
+			name:     "Case 3 extended length",
+			c:        "00A404000000020102",
+			wantLcLe: apdu.LcLe{LcBytes: []byte{0x00, 0x00, 0x02}, Extended: true},
+		},
+		{
+			name:     "Case 4 extended length",
+			c:        "00A4040000000201020000",
+			wantLcLe: apdu.LcLe{LcBytes: []byte{0x00, 0x00, 0x02}, LeBytes: []byte{0x00, 0x00}, Extended: true},
+		},
+		{
+			name:     "extended length zero Lc followed by Le",
+			c:        "00A4040000000000FF",
+			wantLcLe: apdu.LcLe{LcBytes: []byte{0x00, 0x00, 0x00}, LeBytes: []byte{0x00, 0xFF}, Extended: true},
+		},
+		{
+			name:    "error: invalid Lc",
+			c:       "00A40400030102",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			b, err := hex.DecodeString(tt.c)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, gotLcLe, err := apdu.ParseCapduDetailed(b)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseCapduDetailed() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+
+			want, err := apdu.ParseCapdu(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ParseCapduDetailed() got = %v, want %v", got, want)
+			}
+			if !reflect.DeepEqual(gotLcLe, tt.wantLcLe) {
+				t.Errorf("ParseCapduDetailed() LcLe = %+v, want %+v", gotLcLe, tt.wantLcLe)
+			}
+		})
+	}
+}
+
+func TestPeekCase(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		c            []byte
+		wantCase     int
+		wantExtended bool
+		wantErr      bool
+	}{
+		{
+			name:    "error: invalid length",
+			c:       []byte{0x00, 0xA4, 0x04},
+			wantErr: true,
+		},
+		{
+			name:    "error: standard length Lc too big",
+			c:       []byte{0x00, 0xA4, 0x04, 0x01, 0x05, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+			wantErr: true,
+		},
+		{
+			name:    "error: extended length Lc too big",
+			c:       []byte{0x00, 0xA4, 0x04, 0x01, 0x00, 0x00, 0x05, 0x01, 0x02, 0x03, 0x04},
+			wantErr: true,
+		},
+		{
+			name:    "error: HID hack with non zero Le",
+			c:       []byte{0x00, 0xA4, 0x04, 0x00, 0x00, 0x05},
+			wantErr: true,
+		},
+		{
+			name:     "Case 1",
+			c:        []byte{0x00, 0xA4, 0x04, 0x00},
+			wantCase: 1,
+		},
+		{
+			name:     "Case 2 standard length",
+			c:        []byte{0x00, 0xA4, 0x04, 0x00, 0x00},
+			wantCase: 2,
+		},
+		{
+			name:     "Case 2 HID hack",
+			c:        []byte{0x00, 0xA4, 0x04, 0x00, 0x00, 0x00},
+			wantCase: 2,
+		},
+		{
+			name:         "Case 2 extended length",
+			c:            []byte{0x00, 0xA4, 0x04, 0x00, 0x00, 0x01, 0x01},
+			wantCase:     2,
+			wantExtended: true,
+		},
+		{
+			name:     "Case 3 standard length",
+			c:        []byte{0x00, 0xA4, 0x04, 0x00, 0x02, 0x01, 0x02},
+			wantCase: 3,
+		},
+		{
+			name:         "Case 3 extended length",
+			c:            []byte{0x00, 0xA4, 0x04, 0x01, 0x00, 0x00, 0x03, 0x01, 0x02, 0x03},
+			wantCase:     3,
+			wantExtended: true,
+		},
+		{
+			name:     "Case 4 standard length",
+			c:        []byte{0x00, 0xA4, 0x04, 0x00, 0x02, 0x01, 0x02, 0x00},
+			wantCase: 4,
+		},
+		{
+			name:         "Case 4 extended length",
+			c:            []byte{0x00, 0xA4, 0x04, 0x01, 0x00, 0x00, 0x03, 0x01, 0x02, 0x03, 0x00, 0x00},
+			wantCase:     4,
+			wantExtended: true,
+		},
+		{
+			name:         "extended length zero Lc followed by Le",
+			c:            []byte{0x00, 0xA4, 0x04, 0x00, 0x00, 0x00, 0x00, 0x00, 0xFF},
+			wantCase:     2,
+			wantExtended: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			gotCase, gotExtended, err := apdu.PeekCase(tt.c)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("PeekCase() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+			if gotCase != tt.wantCase || gotExtended != tt.wantExtended {
+				t.Errorf("PeekCase() got = (%d, %v), want (%d, %v)", gotCase, gotExtended, tt.wantCase, tt.wantExtended)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			parsed, err := apdu.ParseCapdu(tt.c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := parsed.Case(); got != gotCase {
+				t.Errorf("PeekCase() = %d, but Case() of the parsed Capdu = %d", gotCase, got)
+			}
+		})
+	}
+}
+
+func TestCapdu_Key(t *testing.T) {
+	t.Parallel()
+
+	key := func(c apdu.Capdu) string {
+		t.Helper()
+
+		k, err := c.Key()
+		if err != nil {
+			t.Fatalf("Key() error = %v", err)
+		}
+
+		return k
+	}
+
+	readBinary := apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Ne: 256}
+	readBinaryChannel1 := apdu.Capdu{CLA: 0x01, INS: 0xB0, P1: 0x00, P2: 0x00, Ne: 256}
+
+	if got, want := key(readBinary), "00B0000000"; got != want {
+		t.Errorf("Key() got = %v, want %v", got, want)
+	}
+	if key(readBinary) != key(apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Ne: 256}) {
+		t.Errorf("Key() differs for identical commands")
+	}
+	if key(readBinary) == key(apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Ne: 255}) {
+		t.Errorf("Key() equal for different commands")
+	}
+	if key(readBinary) == key(readBinaryChannel1) {
+		t.Errorf("Key() equal for different logical channels")
+	}
+	if key(readBinary) != key(readBinaryChannel1.WithoutLogicalChannel()) {
+		t.Errorf("Key() differs after WithoutLogicalChannel")
+	}
+	if got, err := (apdu.Capdu{Ne: 65537}).Key(); err == nil {
+		t.Errorf("Key() got = %v, want error for invalid Capdu", got)
+	}
+}
+
+func TestCapdu_Hash(t *testing.T) {
+	t.Parallel()
+
+	hash := func(o apdu.HashOptions, c apdu.Capdu) [32]byte {
+		t.Helper()
+
+		h, err := o.Hash(c)
+		if err != nil {
+			t.Fatalf("Hash() error = %v", err)
+		}
+
+		return h
+	}
+
+	readBinary := apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Ne: 256}
+	readBinaryChannel1 := apdu.Capdu{CLA: 0x01, INS: 0xB0, P1: 0x00, P2: 0x00, Ne: 256}
+
+	got, err := readBinary.Hash()
+	if want := sha256.Sum256([]byte{0x00, 0xB0, 0x00, 0x00, 0x00}); err != nil || got != want {
+		t.Errorf("Hash() got = %X, %v, want %X", got, err, want)
+	}
+	if hash(apdu.HashOptions{}, readBinary) != hash(apdu.HashOptions{}, apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Ne: 256, Label: "read"}) {
+		t.Errorf("Hash() differs for identical commands")
+	}
+	if hash(apdu.HashOptions{}, readBinary) == hash(apdu.HashOptions{}, apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Ne: 255}) {
+		t.Errorf("Hash() equal for different commands")
+	}
+	if hash(apdu.HashOptions{}, readBinary) == hash(apdu.HashOptions{}, readBinaryChannel1) {
+		t.Errorf("Hash() equal for different logical channels")
+	}
+	if hash(apdu.HashOptions{}, readBinary) != hash(apdu.HashOptions{IgnoreLogicalChannel: true}, readBinaryChannel1) {
+		t.Errorf("HashOptions.Hash() differs with IgnoreLogicalChannel")
+	}
+	if got, err := (apdu.Capdu{Ne: 65537}).Hash(); err == nil {
+		t.Errorf("Hash() got = %X, want error for invalid Capdu", got)
+	}
+}
+
+func TestCapdu_TrimTrailingSW(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		opts  apdu.TrimTrailingSWOptions
+		capdu apdu.Capdu
+		want  apdu.Capdu
+	}{
+		{
+			name:  "trailing success SW",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xD6, Data: []byte{0x01, 0x02, 0x90, 0x00}},
+			want:  apdu.Capdu{CLA: 0x00, INS: 0xD6, Data: []byte{0x01, 0x02}},
+		},
+		{
+			name:  "trailing error SW only",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xD6, Data: []byte{0x6A, 0x82}},
+			want:  apdu.Capdu{CLA: 0x00, INS: 0xD6, Data: []byte{}},
+		},
+		{
+			name:  "no trailing SW",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xD6, Data: []byte{0x01, 0x02, 0x03, 0x04}},
+			want:  apdu.Capdu{CLA: 0x00, INS: 0xD6, Data: []byte{0x01, 0x02, 0x03, 0x04}},
+		},
+		{
+			name:  "custom predicate",
+			opts:  apdu.TrimTrailingSWOptions{Predicate: func(sw uint16) bool { return sw == 0x0304 }},
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xD6, Data: []byte{0x01, 0x02, 0x03, 0x04}},
+			want:  apdu.Capdu{CLA: 0x00, INS: 0xD6, Data: []byte{0x01, 0x02}},
+		},
+		{
+			name:  "custom predicate rejecting success SW",
+			opts:  apdu.TrimTrailingSWOptions{Predicate: func(sw uint16) bool { return sw != 0x9000 }},
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xD6, Data: []byte{0x01, 0x02, 0x90, 0x00}},
+			want:  apdu.Capdu{CLA: 0x00, INS: 0xD6, Data: []byte{0x01, 0x02, 0x90, 0x00}},
+		},
+		{
+			name:  "data too short",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xD6, Data: []byte{0x90}},
+			want:  apdu.Capdu{CLA: 0x00, INS: 0xD6, Data: []byte{0x90}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tt.opts.TrimTrailingSW(tt.capdu); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TrimTrailingSW() got = %v, want %v", got, tt.want)
+			}
+			if tt.opts.Predicate == nil {
+				if got := tt.capdu.TrimTrailingSW(); !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("Capdu.TrimTrailingSW() got = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCapdu_CapNe(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		ne     int
+		max    int
+		wantNe int
+		want   string
+	}{
+		{
+			name:   "no Ne",
+			ne:     0,
+			max:    256,
+			wantNe: 0,
+			want:   "00B00000",
+		},
+		{
+			name:   "below max",
+			ne:     16,
+			max:    256,
+			wantNe: 16,
+			want:   "00B0000010",
+		},
+		{
+			name:   "extended capped to standard maximum",
+			ne:     65536,
+			max:    256,
+			wantNe: 256,
+			want:   "00B0000000",
+		},
+		{
+			name:   "extended capped to extended",
+			ne:     65536,
+			max:    1024,
+			wantNe: 1024,
+			want:   "00B00000000400",
+		},
+		{
+			name:   "non positive max",
+			ne:     256,
+			max:    0,
+			wantNe: 256,
+			want:   "00B0000000",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := apdu.Capdu{CLA: 0x00, INS: 0xB0, Ne: tt.ne}.CapNe(tt.max)
+			if got.Ne != tt.wantNe {
+				t.Errorf("CapNe() Ne = %d, want %d", got.Ne, tt.wantNe)
+			}
+			s, err := got.String()
+			if err != nil {
+				t.Fatalf("String() error = %v", err)
+			}
+			if s != tt.want {
+				t.Errorf("CapNe() String() = %s, want %s", s, tt.want)
+			}
+		})
+	}
+}
+
+func TestCapdu_ResponseShortfall(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		ne      int
+		dataLen int
+		want    int
+	}{
+		{
+			name:    "no data expected",
+			ne:      0,
+			dataLen: 0,
+			want:    0,
+		},
+		{
+			name:    "no data expected but returned",
+			ne:      0,
+			dataLen: 4,
+			want:    0,
+		},
+		{
+			name:    "exact",
+			ne:      16,
+			dataLen: 16,
+			want:    0,
+		},
+		{
+			name:    "short standard maximum",
+			ne:      256,
+			dataLen: 200,
+			want:    56,
+		},
+		{
+			name:    "short extended maximum",
+			ne:      65536,
+			dataLen: 256,
+			want:    65280,
+		},
+		{
+			name:    "more than expected",
+			ne:      16,
+			dataLen: 20,
+			want:    0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := apdu.Capdu{CLA: 0x00, INS: 0xB0, Ne: tt.ne}
+			r := apdu.Rapdu{Data: make([]byte, tt.dataLen), SW1: 0x90, SW2: 0x00}
+			if got := c.ResponseShortfall(r); got != tt.want {
+				t.Errorf("ResponseShortfall() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCapdu_LikelyTruncated(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		ne      int
+		dataLen int
+		sw      uint16
+		want    bool
+	}{
+		{
+			name:    "exactly Ne with 9000",
+			ne:      256,
+			dataLen: 256,
+			sw:      0x9000,
+			want:    true,
+		},
+		{
+			name:    "exactly Ne with 61xx",
+			ne:      16,
+			dataLen: 16,
+			sw:      0x6110,
+			want:    true,
+		},
+		{
+			name:    "shorter than Ne",
+			ne:      256,
+			dataLen: 100,
+			sw:      0x9000,
+			want:    false,
+		},
+		{
+			name:    "exactly Ne with warning",
+			ne:      16,
+			dataLen: 16,
+			sw:      0x6282,
+			want:    false,
+		},
+		{
+			name:    "no data expected",
+			ne:      0,
+			dataLen: 0,
+			sw:      0x9000,
+			want:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := apdu.Capdu{CLA: 0x00, INS: 0xB0, Ne: tt.ne}
+			if got := c.LikelyTruncated(apdu.NewRapdu(make([]byte, tt.dataLen), tt.sw)); got != tt.want {
+				t.Errorf("LikelyTruncated() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCapdu_Equal(t *testing.T) {
+	t.Parallel()
+
+	selectMF := apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x00, P2: 0x0C, Data: []byte{0x3F, 0x00}, Label: "Select MF"}
+
+	tests := []struct {
+		name                  string
+		other                 apdu.Capdu
+		wantEqual             bool
+		wantEqualIncludeLabel bool
+	}{
+		{
+			name:                  "identical",
+			other:                 apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x00, P2: 0x0C, Data: []byte{0x3F, 0x00}, Label: "Select MF"},
+			wantEqual:             true,
+			wantEqualIncludeLabel: true,
+		},
+		{
+			name:      "different label",
+			other:     apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x00, P2: 0x0C, Data: []byte{0x3F, 0x00}},
+			wantEqual: true,
+		},
+		{
+			name:      "ExpectedNc set",
+			other:     apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x00, P2: 0x0C, Data: []byte{0x3F, 0x00}, ExpectedNc: 2},
+			wantEqual: true,
+		},
+		{
+			name:  "different data",
+			other: apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x00, P2: 0x0C, Data: []byte{0x3F, 0x01}, Label: "Select MF"},
+		},
+		{
+			name:  "different Ne",
+			other: apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x00, P2: 0x0C, Data: []byte{0x3F, 0x00}, Ne: 256, Label: "Select MF"},
+		},
+		{
+			name:  "different header",
+			other: apdu.Capdu{CLA: 0x01, INS: 0xA4, P1: 0x00, P2: 0x0C, Data: []byte{0x3F, 0x00}, Label: "Select MF"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := selectMF.Equal(tt.other); got != tt.wantEqual {
+				t.Errorf("Equal() = %v, want %v", got, tt.wantEqual)
+			}
+			if got := selectMF.EqualIncludingLabel(tt.other); got != tt.wantEqualIncludeLabel {
+				t.Errorf("EqualIncludingLabel() = %v, want %v", got, tt.wantEqualIncludeLabel)
+			}
+		})
+	}
+}
+
+func TestCapdu_LogAttrs(t *testing.T) {
+	t.Parallel()
+
+	c := apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, Data: []byte{0xA0, 0x00}, Ne: 256, Label: "Select"}
+
+	var buf bytes.Buffer
+	slog.New(slog.NewTextHandler(&buf, nil)).LogAttrs(context.Background(), slog.LevelInfo, "command", c.LogAttrs()...)
+
+	want := "cla=00 ins=A4 p1=04 p2=00 nc=2 ne=256 case=4 extended=false data=A000 label=Select\n"
+	if got := buf.String(); !strings.HasSuffix(got, want) {
+		t.Errorf("LogAttrs() output = %q, want suffix %q", got, want)
+	}
+}
+
+func TestCapdu_EquivalentTo(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want bool
+	}{
+		{
+			name: "standard and extended Le for Ne 256",
+			a:    "00A4040000",
+			b:    "00A40400000100",
+			want: true,
+		},
+		{
+			name: "standard and extended Lc",
+			a:    "00A4040002010200",
+			b:    "00A4040000000201020100",
+			want: true,
+		},
+		{
+			name: "different effective Ne",
+			a:    "00A4040000",
+			b:    "00A40400000000",
+		},
+		{
+			name: "different data",
+			a:    "00A40400020102",
+			b:    "00A40400020103",
+		},
+		{
+			name: "different header",
+			a:    "00A4040000",
+			b:    "00A4040C00",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			a, err := apdu.ParseCapduHexString(tt.a)
+			if err != nil {
+				t.Fatalf("ParseCapduHexString() error = %v", err)
+			}
+			b, err := apdu.ParseCapduHexString(tt.b)
+			if err != nil {
+				t.Fatalf("ParseCapduHexString() error = %v", err)
+			}
+			if got := a.EquivalentTo(b); got != tt.want {
+				t.Errorf("EquivalentTo() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCapdu_LogValueLabel(t *testing.T) {
+	t.Parallel()
+
+	c := apdu.Capdu{CLA: 0x00, INS: 0x20, P1: 0x00, P2: 0x80, Data: []byte{0x31, 0x32}, Label: "Verify PIN"}
+	b, err := c.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes() error = %v", err)
+	}
+	if !reflect.DeepEqual(b, []byte{0x00, 0x20, 0x00, 0x80, 0x02, 0x31, 0x32}) {
+		t.Errorf("Bytes() got = %X, label must not be encoded", b)
+	}
+
+	var gotLabel string
+	for _, attr := range c.LogValue().Group() {
+		if attr.Key == "label" {
+			gotLabel = attr.Value.String()
+		}
+	}
+	if gotLabel != c.Label {
+		t.Errorf("LogValue() label = %q, want %q", gotLabel, c.Label)
+	}
+}
+
+func TestCapdu_ForPCSC(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		capdu       apdu.Capdu
+		wantCommand []byte
+		wantRecvLen int
+		wantErr     bool
+	}{
+		{
+			name:        "Case 1",
+			capdu:       apdu.Capdu{CLA: 0x00, INS: 0x84, P1: 0x00, P2: 0x00},
+			wantCommand: []byte{0x00, 0x84, 0x00, 0x00},
+			wantRecvLen: 2,
+		},
+		{
+			name:        "Case 2 standard length maximum",
+			capdu:       apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Ne: 256},
+			wantCommand: []byte{0x00, 0xB0, 0x00, 0x00, 0x00},
+			wantRecvLen: 258,
+		},
+		{
+			name:        "Case 2 extended length maximum",
+			capdu:       apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Ne: 65536},
+			wantCommand: []byte{0x00, 0xB0, 0x00, 0x00, 0x00, 0x00, 0x00},
+			wantRecvLen: 65538,
+		},
+		{
+			name:    "error: invalid Capdu",
+			capdu:   apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Ne: 65537},
+			wantErr: true,
+		},
+		{
+			name:    "error: negative Ne",
+			capdu:   apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Ne: -5},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			gotCommand, gotRecvLen, err := tt.capdu.ForPCSC()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ForPCSC() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+			if !reflect.DeepEqual(gotCommand, tt.wantCommand) || gotRecvLen != tt.wantRecvLen {
+				t.Errorf("ForPCSC() got = (%X, %d), want (%X, %d)", gotCommand, gotRecvLen, tt.wantCommand, tt.wantRecvLen)
+			}
+		})
+	}
+}
+
+func TestCapdu_Case(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		capdu apdu.Capdu
+		want  int
+	}{
+		{
+			name:  "Case 1",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0x84, P1: 0x00, P2: 0x00},
+			want:  1,
+		},
+		{
+			name:  "Case 2",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Ne: 256},
+			want:  2,
+		},
+		{
+			name:  "Case 3",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xD6, P1: 0x00, P2: 0x00, Data: []byte{0x01}},
+			want:  3,
+		},
+		{
+			name:  "Case 4 extended length",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, Data: make([]byte, 300), Ne: 65536},
+			want:  4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tt.capdu.Case(); got != tt.want {
+				t.Errorf("Case() = %d, want %d", got, tt.want)
+			}
+
+			predicates := []bool{tt.capdu.IsCase1(), tt.capdu.IsCase2(), tt.capdu.IsCase3(), tt.capdu.IsCase4()}
+			for i, got := range predicates {
+				if want := i+1 == tt.want; got != want {
+					t.Errorf("IsCase%d() = %v, want %v", i+1, got, want)
+				}
+			}
 
-	type fields struct {
-		CLA  byte
-		INS  byte
-		P1   byte
-		P2   byte
-		Data []byte
-		Ne   int
+			b, err := tt.capdu.Bytes()
+			if err != nil {
+				t.Fatalf("Bytes() error = %v", err)
+			}
+			if peeked, _, err := apdu.PeekCase(b); err != nil || peeked != tt.want {
+				t.Errorf("PeekCase() of encoding = %d (%v), want %d", peeked, err, tt.want)
+			}
+		})
 	}
+}
+
+func TestCapdu_ValidateResponse(t *testing.T) {
+	t.Parallel()
 
 	tests := []struct {
 		name    string
-		fields  fields
-		want    []byte
+		ne      int
+		dataLen int
 		wantErr bool
 	}{
 		{
-			name:    "standard length CASE 1",
-			fields:  fields{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x01, Ne: 0},
-			want:    []byte{0x00, 0xA4, 0x04, 0x01},
-			wantErr: false,
-		},
-		{
-			name:    "standard length CASE 2 Le unequal zero",
-			fields:  fields{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x01, Ne: 255},
-			want:    []byte{0x00, 0xA4, 0x04, 0x01, 0xFF},
-			wantErr: false,
+			name:    "no data expected nor returned",
+			ne:      0,
+			dataLen: 0,
 		},
 		{
-			name:    "standard length CASE 2 Le equal zero",
-			fields:  fields{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x01, Ne: 256},
-			want:    []byte{0x00, 0xA4, 0x04, 0x01, 0x00},
-			wantErr: false,
+			name:    "error: no data expected but returned",
+			ne:      0,
+			dataLen: 1,
+			wantErr: true,
 		},
 		{
-			name:    "standard length CASE 3",
-			fields:  fields{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x01, Data: []byte{0x01, 0x02, 0x3}, Ne: 0},
-			want:    []byte{0x00, 0xA4, 0x04, 0x01, 0x03, 0x01, 0x02, 0x03},
-			wantErr: false,
+			name:    "less than Ne",
+			ne:      16,
+			dataLen: 8,
 		},
 		{
-			name:    "standard length CASE 4",
-			fields:  fields{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x01, Data: []byte{0x01, 0x02}, Ne: 3},
-			want:    []byte{0x00, 0xA4, 0x04, 0x01, 0x02, 0x01, 0x02, 0x03},
-			wantErr: false,
+			name:    "standard length maximum",
+			ne:      256,
+			dataLen: 256,
 		},
 		{
-			name:    "extended length CASE 2 Le unequal zero",
-			fields:  fields{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x01, Ne: 65535},
-			want:    []byte{0x00, 0xA4, 0x04, 0x01, 0x00, 0xFF, 0xFF},
-			wantErr: false,
+			name:    "error: exceeds standard length maximum",
+			ne:      256,
+			dataLen: 257,
+			wantErr: true,
 		},
 		{
-			name:    "extended length CASE 2 Le equal zero",
-			fields:  fields{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x01, Ne: 65536},
-			want:    []byte{0x00, 0xA4, 0x04, 0x01, 0x00, 0x00, 0x00},
-			wantErr: false,
+			name:    "error: exceeds Ne 255",
+			ne:      255,
+			dataLen: 256,
+			wantErr: true,
 		},
 		{
-			name:    "error: ne invalid CASE 2",
-			fields:  fields{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x01, Ne: 65537},
-			want:    nil,
-			wantErr: true,
+			name:    "extended length maximum",
+			ne:      65536,
+			dataLen: 65536,
 		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := apdu.Capdu{CLA: 0x00, INS: 0xB0, Ne: tt.ne}
+			if err := c.ValidateResponse(apdu.Rapdu{Data: make([]byte, tt.dataLen), SW1: 0x90, SW2: 0x00}); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateResponse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCompareBytes(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		a             apdu.Capdu
+		b             apdu.Capdu
+		wantFirstDiff int
+		wantEqual     bool
+		wantErr       bool
+	}{
 		{
-			name:    "extended length CASE 3",
-			fields:  fields{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x01, Data: extendedData, Ne: 0},
-			want:    append([]byte{0x00, 0xA4, 0x04, 0x01, 0x00, 0xFF, 0xFF}, extendedData...),
-			wantErr: false,
+			name:          "equal",
+			a:             apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, Data: []byte{0xA0, 0x00}, Ne: 256},
+			b:             apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, Data: []byte{0xA0, 0x00}, Ne: 256, Label: "Select"},
+			wantFirstDiff: -1,
+			wantEqual:     true,
 		},
 		{
-			name:    "error: invalid length CASE 3",
-			fields:  fields{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x01, Data: tooExtendedData, Ne: 0},
-			want:    nil,
-			wantErr: true,
+			name:          "different data",
+			a:             apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, Data: []byte{0xA0, 0x00}},
+			b:             apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, Data: []byte{0xA0, 0x01}},
+			wantFirstDiff: 6,
 		},
 		{
-			name:    "extended length CASE 4 Le unequal zero",
-			fields:  fields{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x01, Data: extendedData, Ne: 65535},
-			want:    append(append([]byte{0x00, 0xA4, 0x04, 0x01, 0x00, 0xFF, 0xFF}, extendedData...), []byte{0xFF, 0xFF}...),
-			wantErr: false,
+			name:          "prefix",
+			a:             apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, Data: []byte{0xA0, 0x00}},
+			b:             apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, Data: []byte{0xA0, 0x00}, Ne: 256},
+			wantFirstDiff: 7,
 		},
 		{
-			name:    "extended length CASE 4 Le equal zero",
-			fields:  fields{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x01, Data: extendedData, Ne: 65536},
-			want:    append(append([]byte{0x00, 0xA4, 0x04, 0x01, 0x00, 0xFF, 0xFF}, extendedData...), []byte{0x00, 0x00}...),
-			wantErr: false,
+			name:          "standard and extended Ne",
+			a:             apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Ne: 256},
+			b:             apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Ne: 257},
+			wantFirstDiff: 5,
 		},
 		{
-			name:    "error: data extended length CASE 4",
-			fields:  fields{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x01, Data: tooExtendedData, Ne: 255},
-			want:    nil,
+			name:    "error: invalid a",
+			a:       apdu.Capdu{Ne: 65537},
+			b:       apdu.Capdu{},
 			wantErr: true,
 		},
 		{
-			name:    "error: ne invalid length CASE 4",
-			fields:  fields{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x01, Data: tooExtendedData, Ne: 65537},
-			want:    nil,
+			name:    "error: invalid b",
+			a:       apdu.Capdu{},
+			b:       apdu.Capdu{Ne: 65537},
 			wantErr: true,
 		},
 	}
+
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
 
-			c := apdu.Capdu{
-				CLA:  tt.fields.CLA,
-				INS:  tt.fields.INS,
-				P1:   tt.fields.P1,
-				P2:   tt.fields.P2,
-				Data: tt.fields.Data,
-				Ne:   tt.fields.Ne,
-			}
-			got, err := c.Bytes()
+			gotFirstDiff, gotEqual, err := apdu.CompareBytes(tt.a, tt.b)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("Bytes() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("CompareBytes() error = %v, wantErr %v", err, tt.wantErr)
 
 				return
 			}
-			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("Bytes() got = %v, want %v", got, tt.want)
+			if tt.wantErr {
+				return
+			}
+			if gotFirstDiff != tt.wantFirstDiff || gotEqual != tt.wantEqual {
+				t.Errorf("CompareBytes() got = (%d, %v), want (%d, %v)", gotFirstDiff, gotEqual, tt.wantFirstDiff, tt.wantEqual)
 			}
 		})
 	}
 }
 
-func TestCapdu_IsExtendedLength(t *testing.T) {
+func TestCapdu_SelfConsistent(t *testing.T) {
 	t.Parallel()
 
-	extendedData := make([]byte, 256)
-	for i := range extendedData {
-		extendedData[i] = 0xFF
-	}
-
-	standardData := make([]byte, 255)
-	for i := range standardData {
-		standardData[i] = 0xFF
-	}
-
-	type fields struct {
-		CLA  byte
-		INS  byte
-		P1   byte
-		P2   byte
-		Data []byte
-		Ne   int
-	}
-
 	tests := []struct {
-		name   string
-		fields fields
-		want   bool
+		name  string
+		capdu apdu.Capdu
+		want  bool
 	}{
 		{
-			name:   "extended length ne",
-			fields: fields{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x01, Ne: 257},
-			want:   true,
+			name:  "Case 1",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0x84, P1: 0x00, P2: 0x00},
+			want:  true,
 		},
 		{
-			name:   "extended length data",
-			fields: fields{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x01, Data: extendedData, Ne: 256},
-			want:   true,
+			name:  "Case 4 standard length",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, Data: []byte{0xA0, 0x00}, Ne: 256},
+			want:  true,
 		},
 		{
-			name:   "standard length",
-			fields: fields{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x01, Data: standardData, Ne: 256},
-			want:   false,
+			name:  "Case 3 extended length",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xDA, P1: 0x00, P2: 0x00, Data: make([]byte, 65535)},
+			want:  true,
+		},
+		{
+			name:  "data too long",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xDA, P1: 0x00, P2: 0x00, Data: make([]byte, 65536)},
+			want:  false,
+		},
+		{
+			name:  "ExpectedNc mismatch",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xDA, P1: 0x00, P2: 0x00, Data: []byte{0x01}, ExpectedNc: 2},
+			want:  false,
 		},
 	}
 
@@ -360,78 +2780,185 @@ func TestCapdu_IsExtendedLength(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
 
-			c := apdu.Capdu{
-				CLA:  tt.fields.CLA,
-				INS:  tt.fields.INS,
-				P1:   tt.fields.P1,
-				P2:   tt.fields.P2,
-				Data: tt.fields.Data,
-				Ne:   tt.fields.Ne,
-			}
-			if got := c.IsExtendedLength(); got != tt.want {
-				t.Errorf("IsExtendedLength() = %v, want %v", got, tt.want)
+			if got := tt.capdu.SelfConsistent(); got != tt.want {
+				t.Errorf("SelfConsistent() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
 
-func TestCapdu_String(t *testing.T) {
-	t.Parallel()
-
-	type fields struct {
-		CLA  byte
-		INS  byte
-		P1   byte
-		P2   byte
-		Data []byte
-		Ne   int
-	}
+// malformedCorpus pins how real-world malformed or unusual commands are handled by the parser. Each entry documents the
+// behaviour interoperability with finicky readers depends on.
+var malformedCorpus = []struct {
+	name    string
+	opts    apdu.ParseOptions
+	c       string
+	want    apdu.Capdu
+	wantErr bool
+}{
+	{
+		// HID readers include a zero Lc before a standard Le, parsed as standard Case 2 with Ne 256
+		name: "HID hack zero Lc before Le 00",
+		c:    "00A404000000",
+		want: apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, Ne: 256},
+	},
+	{
+		// only Le 00 has been seen in the wild, anything else after a zero Lc is rejected
+		name:    "zero Lc followed by non zero byte",
+		c:       "00A404000005",
+		wantErr: true,
+	},
+	{
+		// a zero byte followed by two byte is always an extended Case 2, never a zero Lc with data
+		name: "zero Lc followed by two byte",
+		c:    "00A40400000102",
+		want: apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, Ne: 258},
+	},
+	{
+		// a corrupted zero Lc before two data byte can not be told apart from an extended Case 2
+		name: "zero Lc followed by two data byte",
+		c:    "00A4040000AABB",
+		want: apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, Ne: 0xAABB},
+	},
+	{
+		// any longer body after a zero byte must have a matching extended Lc
+		name:    "zero Lc followed by three data byte",
+		c:       "00A4040000AABBCC",
+		wantErr: true,
+	},
+	{
+		name:    "standard Lc with extended Le rejected by default",
+		c:       "00A404000201020100",
+		wantErr: true,
+	},
+	{
+		name: "standard Lc with extended Le accepted if allowed",
+		opts: apdu.ParseOptions{AllowStandardLcExtendedLe: true},
+		c:    "00A404000201020100",
+		want: apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, Data: []byte{0x01, 0x02}, Ne: 256},
+	},
+	{
+		name:    "extended Lc with standard Le",
+		c:       "00A40400000002010200",
+		wantErr: true,
+	},
+	{
+		name: "Le 00 meaning zero if configured",
+		opts: apdu.ParseOptions{LeZeroMeansZero: true},
+		c:    "00A4040002010200",
+		want: apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, ZeroLe: true, Data: []byte{0x01, 0x02}},
+	},
+	{
+		name: "extended Le 0000 without data",
+		c:    "00B00000000000",
+		want: apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Ne: 65536},
+	},
+	{
+		name:    "trailing byte after Case 4",
+		c:       "00A4040002010200FF",
+		wantErr: true,
+	},
+	{
+		// the extended length marker is what selects the extended branch, a non zero marker leaves the frame to the
+		// standard Lc check which rejects it
+		name:    "extended Case 4 with non zero marker",
+		c:       "00A4040001000201020000",
+		wantErr: true,
+	},
+	{
+		name:    "extended Case 3 with non zero marker",
+		c:       "00A404008000020102",
+		wantErr: true,
+	},
+	{
+		// indistinguishable from a valid standard Case 4 with one byte of data
+		name: "extended Case 2 with non zero marker",
+		c:    "00B00000010000",
+		want: apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Data: []byte{0x00}, Ne: 256},
+	},
+	{
+		name:    "Lc exceeding data",
+		c:       "00A40400050102",
+		wantErr: true,
+	},
+}
 
-	tests := []struct {
-		name    string
-		fields  fields
-		want    string
-		wantErr bool
-	}{
-		{
-			name:    "to string",
-			fields:  fields{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x01, Data: []byte{0x01, 0x02}, Ne: 3},
-			want:    "00A4040102010203",
-			wantErr: false,
-		},
-		{
-			name:    "error: invalid ne",
-			fields:  fields{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x01, Data: []byte{0x01, 0x02}, Ne: 65537},
-			want:    "",
-			wantErr: true,
-		},
-	}
+func TestParseCapduMalformedCorpus(t *testing.T) {
+	t.Parallel()
 
-	for _, tt := range tests {
+	for _, tt := range malformedCorpus {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
 
-			c := apdu.Capdu{
-				CLA:  tt.fields.CLA,
-				INS:  tt.fields.INS,
-				P1:   tt.fields.P1,
-				P2:   tt.fields.P2,
-				Data: tt.fields.Data,
-				Ne:   tt.fields.Ne,
+			b, err := hex.DecodeString(tt.c)
+			if err != nil {
+				t.Fatal(err)
 			}
-			got, err := c.String()
+
+			got, err := tt.opts.ParseCapdu(b)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("String() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("ParseCapdu() error = %v, wantErr %v", err, tt.wantErr)
 
 				return
 			}
-			if got != tt.want {
-				t.Errorf("String() got = %v, want %v", got, tt.want)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseCapdu() got = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
 
+func FuzzParseCapdu(f *testing.F) {
+	f.Add([]byte{0x00, 0xA4, 0x04, 0x00})
+	f.Add([]byte{0x00, 0xA4, 0x04, 0x00, 0x00})
+	f.Add([]byte{0x00, 0xA4, 0x04, 0x00, 0x00, 0x00})
+	f.Add([]byte{0x00, 0xA4, 0x04, 0x00, 0x00, 0x01, 0x01})
+	f.Add([]byte{0x00, 0xA4, 0x04, 0x00, 0x02, 0x01, 0x02})
+	f.Add([]byte{0x00, 0xA4, 0x04, 0x00, 0x02, 0x01, 0x02, 0x00})
+	f.Add([]byte{0x00, 0xA4, 0x04, 0x00, 0x00, 0x00, 0x02, 0x01, 0x02})
+	f.Add([]byte{0x00, 0xA4, 0x04, 0x00, 0x00, 0x00, 0x02, 0x01, 0x02, 0x00, 0x00})
+	f.Add([]byte{0x00, 0xA4, 0x04, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00})
+	for _, tt := range malformedCorpus {
+		b, err := hex.DecodeString(tt.c)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(b)
+	}
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		c, err := apdu.ParseCapdu(b)
+
+		caseNum, _, peekErr := apdu.PeekCase(b)
+		if (err != nil) != (peekErr != nil) {
+			t.Fatalf("ParseCapdu() error = %v, PeekCase() error = %v", err, peekErr)
+		}
+		if err != nil {
+			return
+		}
+
+		if !c.SelfConsistent() {
+			t.Fatalf("ParseCapdu() returned inconsistent Capdu %v for %X", c, b)
+		}
+		if caseNum != c.Case() && len(c.Data) > 0 {
+			t.Fatalf("PeekCase() = %d, Case() = %d for %X", caseNum, c.Case(), b)
+		}
+
+		enc, err := c.Bytes()
+		if err != nil {
+			t.Fatalf("Bytes() error = %v for %X", err, b)
+		}
+
+		got, err := apdu.ParseCapdu(enc)
+		if err != nil {
+			t.Fatalf("ParseCapdu() of re-encoding %X error = %v", enc, err)
+		}
+		if !got.Equal(c) {
+			t.Fatalf("ParseCapdu() of re-encoding got = %v, want %v", got, c)
+		}
+	})
+}
+
 func benchmarkParseCapdu(b *testing.B, by []byte) {
 	b.Helper()
 
@@ -470,6 +2997,44 @@ func BenchmarkParseCapduCase4Ext(b *testing.B) {
 	benchmarkParseCapdu(b, []byte{0x00, 0xAA, 0xBB, 0xCC, 0x00, 0x00, 0x05, 0x01, 0x02, 0x03, 0x04, 0x05, 0x00, 0xFF})
 }
 
+func benchmarkPeekCase(b *testing.B, by []byte) {
+	b.Helper()
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		_, _, _ = apdu.PeekCase(by)
+	}
+}
+
+func BenchmarkPeekCaseCase1(b *testing.B) {
+	benchmarkPeekCase(b, []byte{0x00, 0xAA, 0xBB, 0xCC})
+}
+
+func BenchmarkPeekCaseCase2Std(b *testing.B) {
+	benchmarkPeekCase(b, []byte{0x00, 0xAA, 0xBB, 0xCC, 0xDD})
+}
+
+func BenchmarkPeekCaseCase3Std(b *testing.B) {
+	benchmarkPeekCase(b, []byte{0x00, 0xAA, 0xBB, 0xCC, 0x05, 0x01, 0x02, 0x03, 0x04, 0x05})
+}
+
+func BenchmarkPeekCaseCase4Std(b *testing.B) {
+	benchmarkPeekCase(b, []byte{0x00, 0xAA, 0xBB, 0xCC, 0x05, 0x01, 0x02, 0x03, 0x04, 0x05, 0xFF})
+}
+
+func BenchmarkPeekCaseCase2Ext(b *testing.B) {
+	benchmarkPeekCase(b, []byte{0x00, 0xAA, 0xBB, 0xCC, 0x00, 0xDD, 0xEE})
+}
+
+func BenchmarkPeekCaseCase3Ext(b *testing.B) {
+	benchmarkPeekCase(b, []byte{0x00, 0xAA, 0xBB, 0xCC, 0x00, 0x00, 0x05, 0x01, 0x02, 0x03, 0x04, 0x05})
+}
+
+func BenchmarkPeekCaseCase4Ext(b *testing.B) {
+	benchmarkPeekCase(b, []byte{0x00, 0xAA, 0xBB, 0xCC, 0x00, 0x00, 0x05, 0x01, 0x02, 0x03, 0x04, 0x05, 0x00, 0xFF})
+}
+
 func benchmarkParseCapduHexString(b *testing.B, s string) {
 	b.Helper()
 
@@ -480,6 +3045,16 @@ func benchmarkParseCapduHexString(b *testing.B, s string) {
 	}
 }
 
+func BenchmarkParseCapduHexStringInto(b *testing.B) {
+	var buf []byte
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		_, buf, _ = apdu.ParseCapduHexStringInto(buf, "00AABBCC000005010203040500FF")
+	}
+}
+
 func BenchmarkParseCapduHexStringCase1(b *testing.B) {
 	benchmarkParseCapduHexString(b, "00AABBCC")
 }
@@ -545,3 +3120,36 @@ func BenchmarkCapdu_BytesCase3Ext(b *testing.B) {
 func BenchmarkCapdu_BytesCase4Ext(b *testing.B) {
 	benchmarkCapduBytes(b, apdu.Capdu{CLA: 0x00, INS: 0xAA, P1: 0xBB, P2: 0xCC, Data: make([]byte, 256), Ne: 65536})
 }
+
+func BenchmarkCapdu_String(b *testing.B) {
+	c := apdu.Capdu{CLA: 0x00, INS: 0xAA, P1: 0xBB, P2: 0xCC, Data: []byte{0x01, 0x02, 0x03, 0x04, 0x05}, Ne: 0xFF}
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		_, _ = c.String()
+	}
+}
+
+func BenchmarkCapdu_StringBytesHex(b *testing.B) {
+	// the previous String implementation for comparison
+	c := apdu.Capdu{CLA: 0x00, INS: 0xAA, P1: 0xBB, P2: 0xCC, Data: []byte{0x01, 0x02, 0x03, 0x04, 0x05}, Ne: 0xFF}
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		by, _ := c.Bytes()
+		_ = strings.ToUpper(hex.EncodeToString(by))
+	}
+}
+
+func BenchmarkCapdu_AppendHex(b *testing.B) {
+	c := apdu.Capdu{CLA: 0x00, INS: 0xAA, P1: 0xBB, P2: 0xCC, Data: []byte{0x01, 0x02, 0x03, 0x04, 0x05}, Ne: 0xFF}
+	buf := make([]byte, 0, 64)
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		buf, _ = c.AppendHex(buf[:0])
+	}
+}
@@ -0,0 +1,59 @@
+package apdu
+
+import (
+	"fmt"
+	"io"
+)
+
+// ChainCount returns the number of commands needed to send the Data of the Capdu using command chaining with at most
+// maxChunk byte of data per command, i.e. the length of Data divided by maxChunk rounded up, but at least 1.
+// maxChunk must be between 1 and MaxLenCommandDataExtended.
+func (c Capdu) ChainCount(maxChunk int) (int, error) {
+	if maxChunk < 1 || maxChunk > MaxLenCommandDataExtended {
+		return 0, fmt.Errorf("%s: invalid chunk size %d - must be between 1 and %d", packageTag, maxChunk, MaxLenCommandDataExtended)
+	}
+
+	return max((len(c.Data)+maxChunk-1)/maxChunk, 1), nil
+}
+
+// WriteChained splits the Data of the Capdu into commands of at most maxChunk byte of data using command chaining and
+// writes the encoding of each command to w in sequence, without holding all of them in memory.
+// All but the last command have the command chaining bit (0x10) of the CLA set and no Ne, the last command carries
+// the Ne of the Capdu. It returns the total number of byte written and stops on the first write error.
+func (c Capdu) WriteChained(w io.Writer, maxChunk int) (int64, error) {
+	count, err := c.ChainCount(maxChunk)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = c.Validate(); err != nil {
+		return 0, err
+	}
+
+	var (
+		total int64
+		buf   []byte
+	)
+
+	for i := range count {
+		part := Capdu{CLA: c.CLA | 0x10, INS: c.INS, P1: c.P1, P2: c.P2}
+		if i == count-1 {
+			part.CLA = c.CLA
+			part.Ne = c.Ne
+		}
+
+		start := i * maxChunk
+		part.Data = c.Data[start:min(start+maxChunk, len(c.Data))]
+
+		extended := part.IsExtendedLength()
+		buf = part.appendBytes(buf[:0], extended)
+
+		n, err := w.Write(buf)
+		total += int64(n)
+		if err != nil {
+			return total, fmt.Errorf("%s: writing command %d of %d: %w", packageTag, i+1, count, err)
+		}
+	}
+
+	return total, nil
+}
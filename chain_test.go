@@ -0,0 +1,166 @@
+package apdu_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"github.com/nvx/go-apdu"
+	"strings"
+	"testing"
+)
+
+func TestCapdu_ChainCount(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		dataLen  int
+		maxChunk int
+		want     int
+		wantErr  bool
+	}{
+		{
+			name:     "no data",
+			dataLen:  0,
+			maxChunk: 255,
+			want:     1,
+		},
+		{
+			name:     "single chunk",
+			dataLen:  255,
+			maxChunk: 255,
+			want:     1,
+		},
+		{
+			name:     "one byte over",
+			dataLen:  256,
+			maxChunk: 255,
+			want:     2,
+		},
+		{
+			name:     "exact multiple",
+			dataLen:  1000,
+			maxChunk: 250,
+			want:     4,
+		},
+		{
+			name:     "error: zero chunk size",
+			dataLen:  10,
+			maxChunk: 0,
+			wantErr:  true,
+		},
+		{
+			name:     "error: chunk size too big",
+			dataLen:  10,
+			maxChunk: 65536,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := apdu.Capdu{CLA: 0x80, INS: 0xE8, Data: make([]byte, tt.dataLen)}
+			got, err := c.ChainCount(tt.maxChunk)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ChainCount() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ChainCount() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+type failingWriter struct {
+	remaining int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.remaining == 0 {
+		return 0, errors.New("write failed")
+	}
+	w.remaining--
+
+	return len(p), nil
+}
+
+func TestCapdu_WriteChained(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		capdu    apdu.Capdu
+		maxChunk int
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "single command",
+			capdu:    apdu.Capdu{CLA: 0x80, INS: 0xE8, P1: 0x00, P2: 0x00, Data: []byte{0x01, 0x02}, Ne: 256},
+			maxChunk: 255,
+			want:     "80E8000002010200",
+		},
+		{
+			name:     "chained commands",
+			capdu:    apdu.Capdu{CLA: 0x80, INS: 0xE8, P1: 0x00, P2: 0x00, Data: []byte{0x01, 0x02, 0x03, 0x04, 0x05}, Ne: 256},
+			maxChunk: 2,
+			want:     "90E80000020102" + "90E80000020304" + "80E80000010500",
+		},
+		{
+			name:     "no data",
+			capdu:    apdu.Capdu{CLA: 0x00, INS: 0xC0, P1: 0x00, P2: 0x00, Ne: 256},
+			maxChunk: 2,
+			want:     "00C0000000",
+		},
+		{
+			name:     "error: invalid chunk size",
+			capdu:    apdu.Capdu{CLA: 0x80, INS: 0xE8, P1: 0x00, P2: 0x00, Data: []byte{0x01}},
+			maxChunk: 0,
+			wantErr:  true,
+		},
+		{
+			name:     "error: invalid Capdu",
+			capdu:    apdu.Capdu{CLA: 0x80, INS: 0xE8, P1: 0x00, P2: 0x00, Data: []byte{0x01}, Ne: 65537},
+			maxChunk: 255,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			n, err := tt.capdu.WriteChained(&buf, tt.maxChunk)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("WriteChained() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+			if got := strings.ToUpper(hex.EncodeToString(buf.Bytes())); got != tt.want {
+				t.Errorf("WriteChained() wrote %s, want %s", got, tt.want)
+			}
+			if n != int64(buf.Len()) {
+				t.Errorf("WriteChained() n = %d, want %d", n, buf.Len())
+			}
+		})
+	}
+}
+
+func TestCapdu_WriteChainedWriteError(t *testing.T) {
+	t.Parallel()
+
+	c := apdu.Capdu{CLA: 0x80, INS: 0xE8, P1: 0x00, P2: 0x00, Data: []byte{0x01, 0x02, 0x03, 0x04, 0x05}}
+
+	n, err := c.WriteChained(&failingWriter{remaining: 1}, 2)
+	if err == nil {
+		t.Fatal("WriteChained() expected error")
+	}
+	if n != 7 {
+		t.Errorf("WriteChained() n = %d, want 7", n)
+	}
+}
@@ -0,0 +1,176 @@
+package apdu
+
+import (
+	"fmt"
+	"slices"
+)
+
+// SecureMessagingType is the secure messaging indication of a CLA byte.
+type SecureMessagingType int
+
+const (
+	// SecureMessagingNone indicates no secure messaging.
+	SecureMessagingNone SecureMessagingType = iota
+	// SecureMessagingProprietary indicates a proprietary secure messaging format.
+	SecureMessagingProprietary
+	// SecureMessagingHeaderNotProcessed indicates secure messaging according to ISO 7816-4 with the command header not
+	// processed (not authenticated).
+	SecureMessagingHeaderNotProcessed
+	// SecureMessagingHeaderAuthenticated indicates secure messaging according to ISO 7816-4 with the command header
+	// authenticated.
+	SecureMessagingHeaderAuthenticated
+)
+
+// BuildCLA returns the CLA byte for the given logical channel (0 to 19), secure messaging indication and command
+// chaining flag on top of base, which must be 0x00 (interindustry) or 0x80 (proprietary) class.
+// Channels 0 to 3 use the first interindustry class layout (0x0X) and channels 4 to 19 the further interindustry
+// class layout (0x4X to 0x7X), which can only indicate SecureMessagingNone or SecureMessagingHeaderNotProcessed.
+func BuildCLA(base byte, channel int, sm SecureMessagingType, chaining bool) (byte, error) {
+	if base != 0x00 && base != 0x80 {
+		return 0, fmt.Errorf("%s: invalid base class %02X - must be 00 or 80", packageTag, base)
+	}
+
+	if channel < 0 || channel > 19 {
+		return 0, fmt.Errorf("%s: invalid logical channel %d - must be between 0 and 19", packageTag, channel)
+	}
+
+	if sm < SecureMessagingNone || sm > SecureMessagingHeaderAuthenticated {
+		return 0, fmt.Errorf("%s: invalid secure messaging type %d", packageTag, sm)
+	}
+
+	cla := base
+	if chaining {
+		cla |= 0x10
+	}
+
+	if channel < 4 {
+		return cla | byte(sm)<<2 | byte(channel), nil
+	}
+
+	switch sm {
+	case SecureMessagingNone:
+	case SecureMessagingHeaderNotProcessed:
+		cla |= 0x20
+	default:
+		return 0, fmt.Errorf("%s: secure messaging type %d can not be indicated for logical channel %d", packageTag, sm, channel)
+	}
+
+	cla |= 0x40 | byte(channel-4)
+	if cla == 0xFF {
+		return 0, fmt.Errorf("%s: combination results in invalid CLA FF", packageTag)
+	}
+
+	return cla, nil
+}
+
+// CLADecoder decodes the logical channel and secure messaging indication of a CLA byte. It can be replaced with
+// SetCLADecoder for cards using a proprietary CLA layout.
+type CLADecoder interface {
+	// LogicalChannel returns the logical channel number encoded in cla, ok is false if cla can not be decoded.
+	LogicalChannel(cla byte) (channel int, ok bool)
+	// SecureMessaging returns the secure messaging indication encoded in cla, ok is false if cla can not be decoded.
+	SecureMessaging(cla byte) (sm SecureMessagingType, ok bool)
+}
+
+// ISOCLADecoder is the default CLADecoder according to ISO 7816-4.
+// Channels 0 to 3 are decoded from the first interindustry class layout (0x0X) and channels 4 to 19 from the further
+// interindustry class layout (0x4X to 0x7X). The same layout is assumed for proprietary classes with b8 set, as used by
+// GlobalPlatform. The invalid CLA 0xFF can not be decoded.
+type ISOCLADecoder struct{}
+
+// LogicalChannel implements CLADecoder.
+func (ISOCLADecoder) LogicalChannel(cla byte) (channel int, ok bool) {
+	if cla == 0xFF {
+		return 0, false
+	}
+
+	if cla&0x40 == 0 {
+		return int(cla & 0x03), true
+	}
+
+	return int(cla&0x0F) + 4, true
+}
+
+// SecureMessaging implements CLADecoder.
+func (ISOCLADecoder) SecureMessaging(cla byte) (sm SecureMessagingType, ok bool) {
+	if cla == 0xFF {
+		return SecureMessagingNone, false
+	}
+
+	if cla&0x40 == 0 {
+		return SecureMessagingType((cla >> 2) & 0x03), true
+	}
+
+	if cla&0x20 != 0 {
+		return SecureMessagingHeaderNotProcessed, true
+	}
+
+	return SecureMessagingNone, true
+}
+
+var claDecoder CLADecoder = ISOCLADecoder{}
+
+// SetCLADecoder replaces the CLADecoder used by LogicalChannel and SecureMessaging, nil restores ISOCLADecoder.
+// It is not safe to call concurrently with decoding and is meant to be called once during initialisation.
+func SetCLADecoder(d CLADecoder) {
+	if d == nil {
+		d = ISOCLADecoder{}
+	}
+
+	claDecoder = d
+}
+
+// LogicalChannel returns the logical channel number encoded in the CLA byte of the Capdu according to the CLADecoder,
+// by default ISOCLADecoder. ok is false if the CLA can not be decoded, e.g. the invalid value 0xFF.
+func (c Capdu) LogicalChannel() (channel int, ok bool) {
+	return claDecoder.LogicalChannel(c.CLA)
+}
+
+// SecureMessaging returns the secure messaging indication encoded in the CLA byte of the Capdu according to the
+// CLADecoder, by default ISOCLADecoder. ok is false if the CLA can not be decoded, e.g. the invalid value 0xFF.
+func (c Capdu) SecureMessaging() (sm SecureMessagingType, ok bool) {
+	return claDecoder.SecureMessaging(c.CLA)
+}
+
+// ChannelsUsed returns the sorted distinct logical channels the commands in cmds address according to LogicalChannel.
+// Commands whose CLA does not define a logical channel, e.g. the invalid CLA 0xFF, are skipped. Proprietary classes
+// with b8 set are skipped as well unless a CLADecoder other than ISOCLADecoder is set with SetCLADecoder, as ISO
+// 7816-4 does not define their logical channel.
+func ChannelsUsed(cmds []Capdu) []int {
+	_, iso := claDecoder.(ISOCLADecoder)
+
+	var channels []int
+	for _, c := range cmds {
+		if iso && c.CLA&0x80 != 0 {
+			continue
+		}
+		if channel, ok := c.LogicalChannel(); ok && !slices.Contains(channels, channel) {
+			channels = append(channels, channel)
+		}
+	}
+
+	slices.Sort(channels)
+
+	return channels
+}
+
+// WithoutLogicalChannel returns a copy of the Capdu with the CLA addressing the basic logical channel 0. The class,
+// secure messaging and command chaining indications are kept, translated to the first interindustry class layout for
+// channels 4 to 19. A CLA of 0xFF is left unchanged. The ISO 7816-4 layout of ISOCLADecoder is always assumed, a
+// CLADecoder set with SetCLADecoder is not used as it can only decode but not re-encode a CLA.
+func (c Capdu) WithoutLogicalChannel() Capdu {
+	switch {
+	case c.CLA == 0xFF:
+	case c.CLA&0x40 == 0:
+		c.CLA &^= 0x03
+	default:
+		cla := c.CLA & 0x90
+		if c.CLA&0x20 != 0 {
+			// secure messaging, command header not processed
+			cla |= 0x08
+		}
+		c.CLA = cla
+	}
+
+	return c
+}
@@ -0,0 +1,357 @@
+package apdu_test
+
+import (
+	"github.com/nvx/go-apdu"
+	"reflect"
+	"testing"
+)
+
+func TestCapdu_LogicalChannel(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		cla         byte
+		wantChannel int
+		wantOk      bool
+	}{
+		{
+			name:        "basic channel",
+			cla:         0x00,
+			wantChannel: 0,
+			wantOk:      true,
+		},
+		{
+			name:        "first interindustry channel 3 with secure messaging",
+			cla:         0x0F,
+			wantChannel: 3,
+			wantOk:      true,
+		},
+		{
+			name:        "further interindustry channel 4",
+			cla:         0x40,
+			wantChannel: 4,
+			wantOk:      true,
+		},
+		{
+			name:        "further interindustry channel 19 with chaining",
+			cla:         0x5F,
+			wantChannel: 19,
+			wantOk:      true,
+		},
+		{
+			name:        "proprietary channel 2",
+			cla:         0x82,
+			wantChannel: 2,
+			wantOk:      true,
+		},
+		{
+			name:   "invalid CLA",
+			cla:    0xFF,
+			wantOk: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			gotChannel, gotOk := apdu.Capdu{CLA: tt.cla}.LogicalChannel()
+			if gotChannel != tt.wantChannel || gotOk != tt.wantOk {
+				t.Errorf("LogicalChannel() got = (%d, %v), want (%d, %v)", gotChannel, gotOk, tt.wantChannel, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestCapdu_SecureMessaging(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		cla    byte
+		wantSM apdu.SecureMessagingType
+		wantOk bool
+	}{
+		{
+			name:   "no secure messaging",
+			cla:    0x00,
+			wantSM: apdu.SecureMessagingNone,
+			wantOk: true,
+		},
+		{
+			name:   "first interindustry header authenticated on channel 3",
+			cla:    0x0F,
+			wantSM: apdu.SecureMessagingHeaderAuthenticated,
+			wantOk: true,
+		},
+		{
+			name:   "proprietary with header not processed",
+			cla:    0x88,
+			wantSM: apdu.SecureMessagingHeaderNotProcessed,
+			wantOk: true,
+		},
+		{
+			name:   "further interindustry without secure messaging",
+			cla:    0x45,
+			wantSM: apdu.SecureMessagingNone,
+			wantOk: true,
+		},
+		{
+			name:   "further interindustry header not processed",
+			cla:    0x65,
+			wantSM: apdu.SecureMessagingHeaderNotProcessed,
+			wantOk: true,
+		},
+		{
+			name: "invalid CLA",
+			cla:  0xFF,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			gotSM, gotOk := apdu.Capdu{CLA: tt.cla}.SecureMessaging()
+			if gotSM != tt.wantSM {
+				t.Errorf("SecureMessaging() sm = %v, want %v", gotSM, tt.wantSM)
+			}
+			if gotOk != tt.wantOk {
+				t.Errorf("SecureMessaging() ok = %v, want %v", gotOk, tt.wantOk)
+			}
+		})
+	}
+}
+
+type vendorCLADecoder struct{}
+
+func (vendorCLADecoder) LogicalChannel(cla byte) (int, bool) {
+	return int(cla >> 4), true
+}
+
+func (vendorCLADecoder) SecureMessaging(byte) (apdu.SecureMessagingType, bool) {
+	return apdu.SecureMessagingProprietary, true
+}
+
+// TestSetCLADecoder is not parallel as it replaces the package wide CLADecoder.
+func TestSetCLADecoder(t *testing.T) {
+	apdu.SetCLADecoder(vendorCLADecoder{})
+	defer apdu.SetCLADecoder(nil)
+
+	c := apdu.Capdu{CLA: 0x30, INS: 0xA4}
+	if channel, ok := c.LogicalChannel(); channel != 3 || !ok {
+		t.Errorf("LogicalChannel() = %d, %v, want 3, true", channel, ok)
+	}
+	if sm, ok := c.SecureMessaging(); sm != apdu.SecureMessagingProprietary || !ok {
+		t.Errorf("SecureMessaging() = %v, %v, want %v, true", sm, ok, apdu.SecureMessagingProprietary)
+	}
+
+	if got, want := apdu.ChannelsUsed([]apdu.Capdu{c, {CLA: 0x80, INS: 0xCA}}), []int{3, 8}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ChannelsUsed() = %v, want %v", got, want)
+	}
+
+	apdu.SetCLADecoder(nil)
+	if channel, ok := c.LogicalChannel(); channel != 0 || !ok {
+		t.Errorf("LogicalChannel() after reset = %d, %v, want 0, true", channel, ok)
+	}
+}
+
+func TestChannelsUsed(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		cmds []apdu.Capdu
+		want []int
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "basic channel only",
+			cmds: []apdu.Capdu{{CLA: 0x00, INS: 0xA4}, {CLA: 0x00, INS: 0xB0}},
+			want: []int{0},
+		},
+		{
+			name: "multiple channels sorted and distinct",
+			cmds: []apdu.Capdu{{CLA: 0x43, INS: 0xB0}, {CLA: 0x01, INS: 0xA4}, {CLA: 0x00, INS: 0x70}, {CLA: 0x0D, INS: 0xB0}},
+			want: []int{0, 1, 7},
+		},
+		{
+			name: "proprietary class skipped",
+			cmds: []apdu.Capdu{{CLA: 0x81, INS: 0xCA}, {CLA: 0xC2, INS: 0xCA}, {CLA: 0x00, INS: 0xA4}},
+			want: []int{0},
+		},
+		{
+			name: "undefined channel skipped",
+			cmds: []apdu.Capdu{{CLA: 0xFF, INS: 0xCA}, {CLA: 0x02, INS: 0xB0}},
+			want: []int{2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := apdu.ChannelsUsed(tt.cmds); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ChannelsUsed() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCapdu_WithoutLogicalChannel(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		cla  byte
+		want byte
+	}{
+		{
+			name: "basic channel",
+			cla:  0x00,
+			want: 0x00,
+		},
+		{
+			name: "channel 3 with secure messaging and chaining",
+			cla:  0x1F,
+			want: 0x1C,
+		},
+		{
+			name: "proprietary channel 1",
+			cla:  0x81,
+			want: 0x80,
+		},
+		{
+			name: "channel 4",
+			cla:  0x40,
+			want: 0x00,
+		},
+		{
+			name: "channel 19 with secure messaging and chaining",
+			cla:  0x7F,
+			want: 0x18,
+		},
+		{
+			name: "invalid CLA",
+			cla:  0xFF,
+			want: 0xFF,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := apdu.Capdu{CLA: tt.cla, INS: 0xB0}.WithoutLogicalChannel()
+			if got.CLA != tt.want || got.INS != 0xB0 {
+				t.Errorf("WithoutLogicalChannel() got CLA = %02X, want %02X", got.CLA, tt.want)
+			}
+			if channel, ok := got.LogicalChannel(); ok && channel != 0 {
+				t.Errorf("WithoutLogicalChannel() got channel = %d, want 0", channel)
+			}
+		})
+	}
+}
+
+func TestBuildCLA(t *testing.T) {
+	t.Parallel()
+
+	type args struct {
+		base     byte
+		channel  int
+		sm       apdu.SecureMessagingType
+		chaining bool
+	}
+
+	tests := []struct {
+		name    string
+		args    args
+		want    byte
+		wantErr bool
+	}{
+		{
+			name: "basic channel",
+			args: args{base: 0x00, channel: 0, sm: apdu.SecureMessagingNone},
+			want: 0x00,
+		},
+		{
+			name: "proprietary channel 1 with header authenticated",
+			args: args{base: 0x80, channel: 1, sm: apdu.SecureMessagingHeaderAuthenticated},
+			want: 0x8D,
+		},
+		{
+			name: "channel 3 with proprietary secure messaging and chaining",
+			args: args{base: 0x00, channel: 3, sm: apdu.SecureMessagingProprietary, chaining: true},
+			want: 0x17,
+		},
+		{
+			name: "channel 4",
+			args: args{base: 0x00, channel: 4, sm: apdu.SecureMessagingNone},
+			want: 0x40,
+		},
+		{
+			name: "channel 19 with secure messaging and chaining",
+			args: args{base: 0x00, channel: 19, sm: apdu.SecureMessagingHeaderNotProcessed, chaining: true},
+			want: 0x7F,
+		},
+		{
+			name: "proprietary channel 19",
+			args: args{base: 0x80, channel: 19, sm: apdu.SecureMessagingNone},
+			want: 0xCF,
+		},
+		{
+			name:    "error: invalid base",
+			args:    args{base: 0x40, channel: 0, sm: apdu.SecureMessagingNone},
+			wantErr: true,
+		},
+		{
+			name:    "error: negative channel",
+			args:    args{base: 0x00, channel: -1, sm: apdu.SecureMessagingNone},
+			wantErr: true,
+		},
+		{
+			name:    "error: channel too big",
+			args:    args{base: 0x00, channel: 20, sm: apdu.SecureMessagingNone},
+			wantErr: true,
+		},
+		{
+			name:    "error: invalid secure messaging type",
+			args:    args{base: 0x00, channel: 0, sm: 4},
+			wantErr: true,
+		},
+		{
+			name:    "error: header authenticated on channel 4",
+			args:    args{base: 0x00, channel: 4, sm: apdu.SecureMessagingHeaderAuthenticated},
+			wantErr: true,
+		},
+		{
+			name:    "error: invalid CLA FF",
+			args:    args{base: 0x80, channel: 19, sm: apdu.SecureMessagingHeaderNotProcessed, chaining: true},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := apdu.BuildCLA(tt.args.base, tt.args.channel, tt.args.sm, tt.args.chaining)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("BuildCLA() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+			if got != tt.want {
+				t.Errorf("BuildCLA() got = %02X, want %02X", got, tt.want)
+			}
+			if err == nil {
+				if channel, _ := (apdu.Capdu{CLA: got}).LogicalChannel(); channel != tt.args.channel {
+					t.Errorf("BuildCLA() got channel = %d, want %d", channel, tt.args.channel)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package apdu
+
+// Classify guesses whether the raw frame b is a command or a response APDU, e.g. for traces where the direction was not
+// logged. The result is purely heuristic and confidence ranges from 0 (no idea) to 1 (certain):
+//   - a frame that parses as a Capdu (with a valid CLA and an INS outside 6X and 9X) can be a command
+//   - a frame of at least 2 byte ending in a status word with SW1 6X (except 60) or 9X can be a response
+//
+// If only one interpretation is possible it is returned with confidence 1. If both are, the well-known status word
+// '0x9000' favours a response, otherwise a command is assumed with low confidence. If neither is possible, false is
+// returned with confidence 0.
+func Classify(b []byte) (isCommand bool, confidence float64) {
+	canCommand := plausibleCommand(b)
+	canResponse := plausibleResponse(b)
+
+	switch {
+	case canCommand && !canResponse:
+		return true, 1
+	case !canCommand && canResponse:
+		return false, 1
+	case !canCommand && !canResponse:
+		return false, 0
+	}
+
+	if b[len(b)-2] == 0x90 && b[len(b)-1] == 0x00 {
+		return false, 0.75
+	}
+
+	return true, 0.5
+}
+
+func plausibleCommand(b []byte) bool {
+	if len(b) < LenHeader || b[OffsetCLA] == 0xFF {
+		return false
+	}
+
+	if ins := b[OffsetINS] & 0xF0; ins == 0x60 || ins == 0x90 {
+		return false
+	}
+
+	_, err := ParseCapdu(b)
+
+	return err == nil
+}
+
+func plausibleResponse(b []byte) bool {
+	if len(b) < LenResponseTrailer {
+		return false
+	}
+
+	sw1 := b[len(b)-2]
+
+	return (sw1 > 0x60 && sw1 <= 0x6F) || sw1&0xF0 == 0x90
+}
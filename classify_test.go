@@ -0,0 +1,74 @@
+package apdu_test
+
+import (
+	"github.com/nvx/go-apdu"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		b              []byte
+		wantIsCommand  bool
+		wantConfidence float64
+	}{
+		{
+			name:           "status word only",
+			b:              []byte{0x90, 0x00},
+			wantConfidence: 1,
+		},
+		{
+			name:           "short response",
+			b:              []byte{0x01, 0x6A, 0x82},
+			wantConfidence: 1,
+		},
+		{
+			name:           "case 1 command",
+			b:              []byte{0x00, 0xA4, 0x04, 0x00},
+			wantIsCommand:  true,
+			wantConfidence: 1,
+		},
+		{
+			name:           "case 2 command",
+			b:              []byte{0x00, 0xB0, 0x00, 0x00, 0x00},
+			wantIsCommand:  true,
+			wantConfidence: 1,
+		},
+		{
+			name:           "response with invalid INS position",
+			b:              []byte{0x01, 0x61, 0x03, 0x90, 0x00},
+			wantConfidence: 1,
+		},
+		{
+			name:           "ambiguous ending in 9000",
+			b:              []byte{0x00, 0xA4, 0x04, 0x00, 0x01, 0x90, 0x00},
+			wantConfidence: 0.75,
+		},
+		{
+			name:           "ambiguous",
+			b:              []byte{0x00, 0xA4, 0x6A, 0x82},
+			wantIsCommand:  true,
+			wantConfidence: 0.5,
+		},
+		{
+			name: "neither",
+			b:    []byte{0x01},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			gotIsCommand, gotConfidence := apdu.Classify(tt.b)
+			if gotIsCommand != tt.wantIsCommand {
+				t.Errorf("Classify() isCommand = %v, want %v", gotIsCommand, tt.wantIsCommand)
+			}
+			if gotConfidence != tt.wantConfidence {
+				t.Errorf("Classify() confidence = %v, want %v", gotConfidence, tt.wantConfidence)
+			}
+		})
+	}
+}
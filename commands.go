@@ -0,0 +1,191 @@
+package apdu
+
+import "fmt"
+
+const (
+	// InsReadBinary defines the INS byte of the READ BINARY command.
+	InsReadBinary = 0xB0
+	// InsReadRecord defines the INS byte of the READ RECORD command.
+	InsReadRecord = 0xB2
+	// InsSelect defines the INS byte of the SELECT command.
+	InsSelect = 0xA4
+	// InsGetResponse defines the INS byte of the GET RESPONSE command.
+	InsGetResponse = 0xC0
+	// InsVerify defines the INS byte of the VERIFY command.
+	InsVerify = 0x20
+	// InsManageChannel defines the INS byte of the MANAGE CHANNEL command.
+	InsManageChannel = 0x70
+)
+
+var (
+	selectP1Descriptions = map[byte]string{
+		0x00: "MF, DF or EF by file identifier",
+		0x01: "child DF by file identifier",
+		0x02: "EF under current DF by file identifier",
+		0x03: "parent DF of current DF",
+		0x04: "by AID",
+		0x08: "by path from MF",
+		0x09: "by path from current DF",
+	}
+	selectOccurrenceDescriptions = [...]string{"first or only occurrence", "last occurrence", "next occurrence", "previous occurrence"}
+	selectResponseDescriptions   = [...]string{"return FCI", "return FCP", "return FMD", "no response data"}
+)
+
+// WriteInstructions is the set of INS byte IsWrite considers to modify the state of the card, by default the ISO 7816-4
+// instructions writing, erasing, creating, deleting, (de)activating or terminating files and data objects as well as
+// changing or resetting reference data. It may be replaced or modified to match a specific card.
+var WriteInstructions = map[byte]bool{
+	0x04: true, // DEACTIVATE FILE
+	0x0C: true, // ERASE RECORD
+	0x0E: true, // ERASE BINARY
+	0x0F: true, // ERASE BINARY
+	0x24: true, // CHANGE REFERENCE DATA
+	0x2C: true, // RESET RETRY COUNTER
+	0x44: true, // ACTIVATE FILE
+	0xD0: true, // WRITE BINARY
+	0xD1: true, // WRITE BINARY
+	0xD2: true, // WRITE RECORD
+	0xD6: true, // UPDATE BINARY
+	0xD7: true, // UPDATE BINARY
+	0xDA: true, // PUT DATA
+	0xDB: true, // PUT DATA
+	0xDC: true, // UPDATE RECORD
+	0xDD: true, // UPDATE RECORD
+	0xE0: true, // CREATE FILE
+	0xE2: true, // APPEND RECORD
+	0xE4: true, // DELETE FILE
+	0xE6: true, // TERMINATE DF
+	0xE8: true, // TERMINATE EF
+	0xFE: true, // TERMINATE CARD USAGE
+}
+
+// IsWrite returns true if the INS of the Capdu is in WriteInstructions, i.e. the command likely modifies the state of
+// the card, e.g. to skip such commands in a dry-run. The CLA is not considered.
+func (c Capdu) IsWrite() bool {
+	return WriteInstructions[c.INS]
+}
+
+// DescribeSelect returns a human readable description of the selection mode encoded in P1 and P2 of a SELECT command
+// according to ISO 7816-4, e.g. "SELECT by AID, first or only occurrence, return FCI". ok is false if the Capdu is
+// not a SELECT command.
+func (c Capdu) DescribeSelect() (description string, ok bool) {
+	if c.INS != InsSelect {
+		return "", false
+	}
+
+	p1, ok := selectP1Descriptions[c.P1]
+	switch {
+	case !ok:
+		p1 = fmt.Sprintf("with P1 %02X", c.P1)
+	case c.P1 == 0x00 && len(c.Data) == 0:
+		p1 = "MF"
+	}
+
+	description = fmt.Sprintf("SELECT %s, %s, %s", p1, selectOccurrenceDescriptions[c.P2&0x03], selectResponseDescriptions[(c.P2>>2)&0x03])
+	if c.P2&0xF0 != 0 {
+		description += fmt.Sprintf(", P2 %02X", c.P2)
+	}
+
+	return description, true
+}
+
+// IsGetResponse returns true if the Capdu is a GET RESPONSE command (INS 0xC0), otherwise false.
+// A '0x61xx' response to a GET RESPONSE is a further chunk of the same response to be fetched with another GET RESPONSE
+// rather than a reason to re-issue the original command.
+func (c Capdu) IsGetResponse() bool {
+	return c.INS == InsGetResponse
+}
+
+// ManageChannelOp decodes a MANAGE CHANNEL command (INS 0x70). P1 0x00 opens and P1 0x80 closes a logical channel,
+// P2 is the channel to open or close (1 to 19). When opening with P2 0x00 the card assigns the channel and returns it
+// in the response data, channel is 0 then. ok is false for other commands, other P1 values or a P2 above 19.
+func (c Capdu) ManageChannelOp() (open bool, channel int, ok bool) {
+	if c.INS != InsManageChannel || c.P2 > 19 {
+		return false, 0, false
+	}
+
+	switch c.P1 {
+	case 0x00:
+		return true, int(c.P2), true
+	case 0x80:
+		return false, int(c.P2), true
+	default:
+		return false, 0, false
+	}
+}
+
+// ValidateSemantic checks the Capdu against a small set of well-known instruction specific rules of ISO 7816-4 that
+// Validate does not cover:
+//   - a SELECT by DF name (INS 0xA4, P1 0x04) must carry the name in Data
+//   - a GET RESPONSE (INS 0xC0) must not carry Data
+//   - a VERIFY (INS 0x20) must not expect response data (Ne must be 0)
+//
+// The checks are opt-in and independent of Validate, which should be called as well to check the Capdu can be encoded.
+func (c Capdu) ValidateSemantic() error {
+	switch c.INS {
+	case InsSelect:
+		if c.P1 == 0x04 && len(c.Data) == 0 {
+			return fmt.Errorf("%s: SELECT by DF name without data", packageTag)
+		}
+	case InsGetResponse:
+		if len(c.Data) != 0 {
+			return fmt.Errorf("%s: GET RESPONSE with data of length %d", packageTag, len(c.Data))
+		}
+	case InsVerify:
+		if c.Ne != 0 {
+			return fmt.Errorf("%s: VERIFY with ne %d", packageTag, c.Ne)
+		}
+	}
+
+	return nil
+}
+
+// ReadBinarySFI returns a READ BINARY command reading ne byte from offset of the EF with the short EF identifier sfi
+// (1 to 30). P1 has b8 set and the SFI in b5 to b1, P2 is the offset within the EF.
+func ReadBinarySFI(sfi byte, offset byte, ne int) (Capdu, error) {
+	if sfi < 1 || sfi > 30 {
+		return Capdu{}, fmt.Errorf("%s: invalid short EF identifier %d - must be between 1 and 30", packageTag, sfi)
+	}
+
+	return Capdu{CLA: 0x00, INS: InsReadBinary, P1: 0x80 | sfi, P2: offset, Ne: ne}, nil
+}
+
+// ReadRecordSFI returns a READ RECORD command reading the record with the given number (1 to 254) of the EF with the
+// short EF identifier sfi (1 to 30). P2 has the SFI in b8 to b4 and b3 to b1 set to 100 (read record P1).
+func ReadRecordSFI(sfi byte, record byte, ne int) (Capdu, error) {
+	if sfi < 1 || sfi > 30 {
+		return Capdu{}, fmt.Errorf("%s: invalid short EF identifier %d - must be between 1 and 30", packageTag, sfi)
+	}
+
+	if record < 1 || record > 254 {
+		return Capdu{}, fmt.Errorf("%s: invalid record number %d - must be between 1 and 254", packageTag, record)
+	}
+
+	return Capdu{CLA: 0x00, INS: InsReadRecord, P1: record, P2: sfi<<3 | 0x04, Ne: ne}, nil
+}
+
+// BinarySFI returns the short EF identifier and offset of a READ BINARY command addressing an EF by SFI as built by
+// ReadBinarySFI. ok is false for other commands.
+func (c Capdu) BinarySFI() (sfi byte, offset byte, ok bool) {
+	if c.INS != InsReadBinary || c.P1&0xE0 != 0x80 {
+		return 0, 0, false
+	}
+
+	sfi = c.P1 & 0x1F
+	if sfi < 1 || sfi > 30 {
+		return 0, 0, false
+	}
+
+	return sfi, c.P2, true
+}
+
+// SelectedAID returns the data of a SELECT by DF name command (INS 0xA4, P1 0x04), which is usually the AID of the
+// application being selected. The raw data bytes are returned as is and may be a partial AID or empty; callers should
+// validate them as needed. ok is false for other commands.
+func (c Capdu) SelectedAID() (aid []byte, ok bool) {
+	if c.INS != InsSelect || c.P1 != 0x04 {
+		return nil, false
+	}
+
+	return c.Data, true
+}
@@ -0,0 +1,467 @@
+package apdu_test
+
+import (
+	"github.com/nvx/go-apdu"
+	"reflect"
+	"testing"
+)
+
+func TestCapdu_DescribeSelect(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		capdu  apdu.Capdu
+		want   string
+		wantOk bool
+	}{
+		{
+			name:   "by AID",
+			capdu:  apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, Data: []byte{0xA0, 0x00, 0x00, 0x00, 0x03}, Ne: 256},
+			want:   "SELECT by AID, first or only occurrence, return FCI",
+			wantOk: true,
+		},
+		{
+			name:   "by AID next occurrence return FCP",
+			capdu:  apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x06, Data: []byte{0xA0, 0x00}, Ne: 256},
+			want:   "SELECT by AID, next occurrence, return FCP",
+			wantOk: true,
+		},
+		{
+			name:   "MF",
+			capdu:  apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x00, P2: 0x0C},
+			want:   "SELECT MF, first or only occurrence, no response data",
+			wantOk: true,
+		},
+		{
+			name:   "by file identifier",
+			capdu:  apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x00, P2: 0x0C, Data: []byte{0x3F, 0x00}},
+			want:   "SELECT MF, DF or EF by file identifier, first or only occurrence, no response data",
+			wantOk: true,
+		},
+		{
+			name:   "by path from MF return FMD",
+			capdu:  apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x08, P2: 0x08, Data: []byte{0x7F, 0x10}, Ne: 256},
+			want:   "SELECT by path from MF, first or only occurrence, return FMD",
+			wantOk: true,
+		},
+		{
+			name:   "unknown P1 and proprietary P2 bits",
+			capdu:  apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x10, P2: 0x81},
+			want:   "SELECT with P1 10, last occurrence, return FCI, P2 81",
+			wantOk: true,
+		},
+		{
+			name:   "not a SELECT",
+			capdu:  apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x04, P2: 0x00, Ne: 256},
+			wantOk: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, gotOk := tt.capdu.DescribeSelect()
+			if got != tt.want || gotOk != tt.wantOk {
+				t.Errorf("DescribeSelect() got = (%q, %v), want (%q, %v)", got, gotOk, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestCapdu_IsWrite(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		capdu apdu.Capdu
+		want  bool
+	}{
+		{
+			name:  "UPDATE BINARY",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xD6, P1: 0x00, P2: 0x00, Data: []byte{0x01}},
+			want:  true,
+		},
+		{
+			name:  "PUT DATA",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xDA, P1: 0x00, P2: 0x5F, Data: []byte{0x01}},
+			want:  true,
+		},
+		{
+			name:  "DELETE FILE",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xE4, P1: 0x00, P2: 0x00},
+			want:  true,
+		},
+		{
+			name:  "READ BINARY",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Ne: 256},
+		},
+		{
+			name:  "SELECT",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, Data: []byte{0xA0, 0x00}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tt.capdu.IsWrite(); got != tt.want {
+				t.Errorf("IsWrite() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCapdu_IsGetResponse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		capdu apdu.Capdu
+		want  bool
+	}{
+		{
+			name:  "GET RESPONSE",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xC0, Ne: 256},
+			want:  true,
+		},
+		{
+			name:  "GET RESPONSE derived for channel 2",
+			capdu: apdu.Capdu{CLA: 0x02, INS: 0xA4, P1: 0x04, Data: []byte{0xA0}}.GetResponse(16),
+			want:  true,
+		},
+		{
+			name:  "SELECT",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, Data: []byte{0xA0}},
+			want:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tt.capdu.IsGetResponse(); got != tt.want {
+				t.Errorf("IsGetResponse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCapdu_ValidateSemantic(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		capdu   apdu.Capdu
+		wantErr bool
+	}{
+		{
+			name:  "SELECT by AID",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, Data: []byte{0xA0, 0x00, 0x00, 0x00, 0x03}, Ne: 256},
+		},
+		{
+			name:  "SELECT MF without data",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x00, P2: 0x00, Ne: 256},
+		},
+		{
+			name:  "GET RESPONSE",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xC0, P1: 0x00, P2: 0x00, Ne: 256},
+		},
+		{
+			name:  "VERIFY",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0x20, P1: 0x00, P2: 0x80, Data: []byte{0x31, 0x32, 0x33, 0x34}},
+		},
+		{
+			name:  "unknown instruction",
+			capdu: apdu.Capdu{CLA: 0x80, INS: 0xCA, P1: 0x9F, P2: 0x7F, Data: []byte{0x01}, Ne: 256},
+		},
+		{
+			name:    "error: SELECT by AID without data",
+			capdu:   apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, Ne: 256},
+			wantErr: true,
+		},
+		{
+			name:    "error: GET RESPONSE with data",
+			capdu:   apdu.Capdu{CLA: 0x00, INS: 0xC0, P1: 0x00, P2: 0x00, Data: []byte{0x01}, Ne: 256},
+			wantErr: true,
+		},
+		{
+			name:    "error: VERIFY with Ne",
+			capdu:   apdu.Capdu{CLA: 0x00, INS: 0x20, P1: 0x00, P2: 0x80, Data: []byte{0x31, 0x32, 0x33, 0x34}, Ne: 256},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if err := tt.capdu.ValidateSemantic(); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateSemantic() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReadBinarySFI(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		sfi     byte
+		offset  byte
+		ne      int
+		want    apdu.Capdu
+		wantErr bool
+	}{
+		{
+			name:   "SFI 1",
+			sfi:    1,
+			offset: 0,
+			ne:     256,
+			want:   apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x81, P2: 0x00, Ne: 256},
+		},
+		{
+			name:   "SFI 30 with offset",
+			sfi:    30,
+			offset: 0x10,
+			ne:     8,
+			want:   apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x9E, P2: 0x10, Ne: 8},
+		},
+		{
+			name:    "error: SFI 0",
+			sfi:     0,
+			wantErr: true,
+		},
+		{
+			name:    "error: SFI 31",
+			sfi:     31,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := apdu.ReadBinarySFI(tt.sfi, tt.offset, tt.ne)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ReadBinarySFI() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadBinarySFI() got = %v, want %v", got, tt.want)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			sfi, offset, ok := got.BinarySFI()
+			if sfi != tt.sfi || offset != tt.offset || !ok {
+				t.Errorf("BinarySFI() got = (%d, %d, %v), want (%d, %d, true)", sfi, offset, ok, tt.sfi, tt.offset)
+			}
+		})
+	}
+}
+
+func TestReadRecordSFI(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		sfi     byte
+		record  byte
+		ne      int
+		want    apdu.Capdu
+		wantErr bool
+	}{
+		{
+			name:   "SFI 1 record 1",
+			sfi:    1,
+			record: 1,
+			ne:     256,
+			want:   apdu.Capdu{CLA: 0x00, INS: 0xB2, P1: 0x01, P2: 0x0C, Ne: 256},
+		},
+		{
+			name:   "SFI 30 record 254",
+			sfi:    30,
+			record: 254,
+			ne:     256,
+			want:   apdu.Capdu{CLA: 0x00, INS: 0xB2, P1: 0xFE, P2: 0xF4, Ne: 256},
+		},
+		{
+			name:    "error: SFI 31",
+			sfi:     31,
+			record:  1,
+			wantErr: true,
+		},
+		{
+			name:    "error: record 0",
+			sfi:     1,
+			record:  0,
+			wantErr: true,
+		},
+		{
+			name:    "error: record 255",
+			sfi:     1,
+			record:  255,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := apdu.ReadRecordSFI(tt.sfi, tt.record, tt.ne)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ReadRecordSFI() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadRecordSFI() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCapdu_BinarySFI(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		capdu apdu.Capdu
+	}{
+		{
+			name:  "READ BINARY with offset in P1",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x01, P2: 0x00, Ne: 256},
+		},
+		{
+			name:  "READ BINARY with RFU P1 bits",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0xC1, P2: 0x00, Ne: 256},
+		},
+		{
+			name:  "READ BINARY with SFI 31",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x9F, P2: 0x00, Ne: 256},
+		},
+		{
+			name:  "UPDATE BINARY",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xD6, P1: 0x81, P2: 0x00, Data: []byte{0x01}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, _, ok := tt.capdu.BinarySFI(); ok {
+				t.Errorf("BinarySFI() ok = true, want false")
+			}
+		})
+	}
+}
+
+func TestCapdu_SelectedAID(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		capdu   apdu.Capdu
+		wantAID []byte
+		wantOk  bool
+	}{
+		{
+			name:    "SELECT by AID",
+			capdu:   apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, Data: []byte{0xA0, 0x00, 0x00, 0x00, 0x03}, Ne: 256},
+			wantAID: []byte{0xA0, 0x00, 0x00, 0x00, 0x03},
+			wantOk:  true,
+		},
+		{
+			name:   "SELECT by AID without data",
+			capdu:  apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, Ne: 256},
+			wantOk: true,
+		},
+		{
+			name:  "SELECT by file identifier",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x00, P2: 0x00, Data: []byte{0x3F, 0x00}},
+		},
+		{
+			name:  "READ BINARY",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x04, P2: 0x00, Ne: 256},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			gotAID, gotOk := tt.capdu.SelectedAID()
+			if !reflect.DeepEqual(gotAID, tt.wantAID) {
+				t.Errorf("SelectedAID() aid = %X, want %X", gotAID, tt.wantAID)
+			}
+			if gotOk != tt.wantOk {
+				t.Errorf("SelectedAID() ok = %v, want %v", gotOk, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestCapdu_ManageChannelOp(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		capdu       apdu.Capdu
+		wantOpen    bool
+		wantChannel int
+		wantOk      bool
+	}{
+		{
+			name:     "open with channel assigned by card",
+			capdu:    apdu.Capdu{CLA: 0x00, INS: 0x70, P1: 0x00, P2: 0x00, Ne: 1},
+			wantOpen: true,
+			wantOk:   true,
+		},
+		{
+			name:        "open channel 2",
+			capdu:       apdu.Capdu{CLA: 0x00, INS: 0x70, P1: 0x00, P2: 0x02},
+			wantOpen:    true,
+			wantChannel: 2,
+			wantOk:      true,
+		},
+		{
+			name:        "close channel 19",
+			capdu:       apdu.Capdu{CLA: 0x03, INS: 0x70, P1: 0x80, P2: 0x13},
+			wantChannel: 19,
+			wantOk:      true,
+		},
+		{
+			name:  "invalid P1",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0x70, P1: 0x01, P2: 0x01},
+		},
+		{
+			name:  "invalid channel",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0x70, P1: 0x80, P2: 0x14},
+		},
+		{
+			name:  "other command",
+			capdu: apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x00, P2: 0x00},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			open, channel, ok := tt.capdu.ManageChannelOp()
+			if open != tt.wantOpen || channel != tt.wantChannel || ok != tt.wantOk {
+				t.Errorf("ManageChannelOp() = %v, %d, %v, want %v, %d, %v", open, channel, ok, tt.wantOpen, tt.wantChannel, tt.wantOk)
+			}
+		})
+	}
+}
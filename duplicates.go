@@ -0,0 +1,53 @@
+package apdu
+
+// DuplicateOptions configures how FindDuplicates compares commands. The zero value compares with EquivalentTo.
+type DuplicateOptions struct {
+	// IgnoreLogicalChannel compares the commands as if sent on the basic logical channel, see WithoutLogicalChannel,
+	// so the same command sent on different logical channels is reported as duplicate.
+	IgnoreLogicalChannel bool
+}
+
+// FindDuplicates returns groups of indices of commands in cmds that are EquivalentTo each other, e.g. to spot
+// retransmissions in a captured trace. Commands on different logical channels are not duplicates, use
+// DuplicateOptions to ignore the logical channel.
+func FindDuplicates(cmds []Capdu) [][]int {
+	return DuplicateOptions{}.FindDuplicates(cmds)
+}
+
+// FindDuplicates returns groups of indices of commands in cmds that are equivalent according to the options. Only
+// groups with at least two commands are returned, ordered by their first index, indices within a group are ascending.
+func (o DuplicateOptions) FindDuplicates(cmds []Capdu) [][]int {
+	type key struct {
+		cla, ins, p1, p2 byte
+		zeroLe           bool
+		ne               int
+		data             string
+	}
+
+	groups := make(map[key]int)
+	var all [][]int
+
+	for i, c := range cmds {
+		if o.IgnoreLogicalChannel {
+			c = c.WithoutLogicalChannel()
+		}
+
+		k := key{cla: c.CLA, ins: c.INS, p1: c.P1, p2: c.P2, zeroLe: c.ZeroLe, ne: c.Ne, data: string(c.Data)}
+		if g, ok := groups[k]; ok {
+			all[g] = append(all[g], i)
+			continue
+		}
+
+		groups[k] = len(all)
+		all = append(all, []int{i})
+	}
+
+	var duplicates [][]int
+	for _, g := range all {
+		if len(g) > 1 {
+			duplicates = append(duplicates, g)
+		}
+	}
+
+	return duplicates
+}
@@ -0,0 +1,66 @@
+package apdu_test
+
+import (
+	"github.com/nvx/go-apdu"
+	"reflect"
+	"testing"
+)
+
+func TestFindDuplicates(t *testing.T) {
+	t.Parallel()
+
+	selectMF := apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x00, P2: 0x0C, Data: []byte{0x3F, 0x00}}
+	selectMFChannel1 := apdu.Capdu{CLA: 0x01, INS: 0xA4, P1: 0x00, P2: 0x0C, Data: []byte{0x3F, 0x00}}
+	readBinary := apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Ne: 256}
+
+	tests := []struct {
+		name string
+		opts apdu.DuplicateOptions
+		cmds []apdu.Capdu
+		want [][]int
+	}{
+		{
+			name: "no duplicates",
+			cmds: []apdu.Capdu{selectMF, readBinary},
+		},
+		{
+			name: "duplicates",
+			cmds: []apdu.Capdu{readBinary, selectMF, readBinary, selectMF, readBinary},
+			want: [][]int{{0, 2, 4}, {1, 3}},
+		},
+		{
+			name: "label and empty data ignored",
+			cmds: []apdu.Capdu{readBinary, {CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Data: []byte{}, Ne: 256, Label: "Read"}},
+			want: [][]int{{0, 1}},
+		},
+		{
+			name: "explicit zero Le differs from no Le",
+			cmds: []apdu.Capdu{{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, ZeroLe: true}, {CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00}},
+		},
+		{
+			name: "different logical channels",
+			cmds: []apdu.Capdu{selectMF, selectMFChannel1},
+		},
+		{
+			name: "ignore logical channels",
+			opts: apdu.DuplicateOptions{IgnoreLogicalChannel: true},
+			cmds: []apdu.Capdu{selectMF, readBinary, selectMFChannel1},
+			want: [][]int{{0, 2}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tt.opts.FindDuplicates(tt.cmds); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FindDuplicates() = %v, want %v", got, tt.want)
+			}
+			if tt.opts == (apdu.DuplicateOptions{}) {
+				if got := apdu.FindDuplicates(tt.cmds); !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("FindDuplicates() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
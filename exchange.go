@@ -0,0 +1,153 @@
+package apdu
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// ExchangeRecord is one logical card operation: a command and its response reassembled from one or more response
+// frames, e.g. a '0x61xx' response followed by GET RESPONSE fetches.
+type ExchangeRecord struct {
+	Command  Capdu // Command is the command sent to the card.
+	Response Rapdu // Response holds the concatenated data of all frames and the status word of the last frame.
+	Frames   int   // Frames is the number of response frames the Response was reassembled from.
+}
+
+// Continuations returns the number of frames following the first one, i.e. GET RESPONSE round trips.
+func (e ExchangeRecord) Continuations() int {
+	return max(e.Frames-1, 0)
+}
+
+// Exchange reassembles the responses to the command c into a single ExchangeRecord. All but the last response must
+// have a '0x61xx' status word indicating more data is available, the data of all responses is concatenated in order.
+func Exchange(c Capdu, responses []Rapdu) (ExchangeRecord, error) {
+	if len(responses) == 0 {
+		return ExchangeRecord{}, fmt.Errorf("%s: no responses to reassemble", packageTag)
+	}
+
+	var data []byte
+	for i, r := range responses {
+		if i < len(responses)-1 && r.SW1 != 0x61 {
+			return ExchangeRecord{}, fmt.Errorf("%s: response %d of %d has status word %04X - expected 61XX", packageTag, i+1, len(responses), r.SW())
+		}
+		data = append(data, r.Data...)
+	}
+
+	last := responses[len(responses)-1]
+
+	return ExchangeRecord{
+		Command:  c,
+		Response: Rapdu{Data: data, SW1: last.SW1, SW2: last.SW2},
+		Frames:   len(responses),
+	}, nil
+}
+
+// TaggedFrame is a raw APDU of a trace together with its direction.
+type TaggedFrame struct {
+	Dir  Direction // Dir is ToCard for a command and FromCard for a response.
+	Data []byte    // Data is the encoded APDU.
+}
+
+// PairExchanges walks the time-ordered frames and pairs each command with the following response. GET RESPONSE
+// commands following a '0x61xx' response and their responses are folded into the exchange of the original command.
+// It returns an error if a frame can not be parsed, a response has no preceding command, a command is not followed by
+// a response or the direction of a frame is invalid.
+func PairExchanges(frames []TaggedFrame) ([]ExchangeRecord, error) {
+	var (
+		exchanges []ExchangeRecord
+		command   Capdu
+		responses []Rapdu
+		pending   bool // a command was seen
+		awaiting  bool // the last command has no response yet
+	)
+
+	flush := func() error {
+		if !pending {
+			return nil
+		}
+
+		e, err := Exchange(command, responses)
+		if err != nil {
+			return err
+		}
+		exchanges = append(exchanges, e)
+
+		return nil
+	}
+
+	for i, f := range frames {
+		switch f.Dir {
+		case ToCard:
+			c, err := ParseCapdu(f.Data)
+			if err != nil {
+				return nil, fmt.Errorf("%s: frame %d: %w", packageTag, i, err)
+			}
+
+			if awaiting {
+				return nil, fmt.Errorf("%s: frame %d: command not preceded by a response to the previous command", packageTag, i)
+			}
+
+			if pending && responses[len(responses)-1].SW1 == 0x61 && c.IsGetResponse() {
+				awaiting = true
+				continue
+			}
+
+			if err = flush(); err != nil {
+				return nil, fmt.Errorf("%s: frame %d: %w", packageTag, i, err)
+			}
+
+			command, responses, pending, awaiting = c, nil, true, true
+		case FromCard:
+			r, err := ParseRapdu(f.Data)
+			if err != nil {
+				return nil, fmt.Errorf("%s: frame %d: %w", packageTag, i, err)
+			}
+
+			if !awaiting {
+				return nil, fmt.Errorf("%s: frame %d: response without command", packageTag, i)
+			}
+
+			responses = append(responses, r)
+			awaiting = false
+		default:
+			return nil, fmt.Errorf("%s: frame %d: invalid direction %d", packageTag, i, int(f.Dir))
+		}
+	}
+
+	if awaiting {
+		return nil, fmt.Errorf("%s: command at end of trace without response", packageTag)
+	}
+
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	return exchanges, nil
+}
+
+// String returns the hex encoded command and response separated by an arrow, e.g. "00B0000000 => 01029000".
+// Encoding errors are included in place of the hex string.
+func (e ExchangeRecord) String() string {
+	c, err := e.Command.String()
+	if err != nil {
+		c = err.Error()
+	}
+
+	r, err := e.Response.String()
+	if err != nil {
+		r = err.Error()
+	}
+
+	return c + " => " + r
+}
+
+// LogValue implements slog.LogValuer grouping the command, the reassembled response, the number of frames and of
+// continuations.
+func (e ExchangeRecord) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Any("command", e.Command),
+		slog.Any("response", e.Response),
+		slog.Int("frames", e.Frames),
+		slog.Int("continuations", e.Continuations()),
+	)
+}
@@ -0,0 +1,240 @@
+package apdu_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"github.com/nvx/go-apdu"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExchange(t *testing.T) {
+	t.Parallel()
+
+	command := apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Ne: 256}
+
+	tests := []struct {
+		name      string
+		responses []apdu.Rapdu
+		want      apdu.ExchangeRecord
+		wantErr   bool
+	}{
+		{
+			name:      "single response",
+			responses: []apdu.Rapdu{apdu.NewRapdu([]byte{0x01, 0x02}, 0x9000)},
+			want:      apdu.ExchangeRecord{Command: command, Response: apdu.NewRapdu([]byte{0x01, 0x02}, 0x9000), Frames: 1},
+		},
+		{
+			name: "GET RESPONSE chain",
+			responses: []apdu.Rapdu{
+				apdu.NewRapdu([]byte{0x01, 0x02}, 0x6102),
+				apdu.NewRapdu([]byte{0x03}, 0x6101),
+				apdu.NewRapdu([]byte{0x04}, 0x6282),
+			},
+			want: apdu.ExchangeRecord{Command: command, Response: apdu.NewRapdu([]byte{0x01, 0x02, 0x03, 0x04}, 0x6282), Frames: 3},
+		},
+		{
+			name:    "error: no responses",
+			wantErr: true,
+		},
+		{
+			name: "error: intermediate response not 61XX",
+			responses: []apdu.Rapdu{
+				apdu.NewRapdu([]byte{0x01, 0x02}, 0x9000),
+				apdu.NewRapdu([]byte{0x03}, 0x9000),
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := apdu.Exchange(command, tt.responses)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Exchange() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Exchange() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPairExchanges(t *testing.T) {
+	t.Parallel()
+
+	frame := func(dir apdu.Direction, s string) apdu.TaggedFrame {
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return apdu.TaggedFrame{Dir: dir, Data: b}
+	}
+
+	selectMF := apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x00, P2: 0x0C, Data: []byte{0x3F, 0x00}}
+	readBinary := apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Ne: 256}
+
+	tests := []struct {
+		name    string
+		frames  []apdu.TaggedFrame
+		want    []apdu.ExchangeRecord
+		wantErr bool
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "command response pairs",
+			frames: []apdu.TaggedFrame{
+				frame(apdu.ToCard, "00A4000C023F00"),
+				frame(apdu.FromCard, "9000"),
+				frame(apdu.ToCard, "00B0000000"),
+				frame(apdu.FromCard, "01029000"),
+			},
+			want: []apdu.ExchangeRecord{
+				{Command: selectMF, Response: apdu.NewRapdu(nil, 0x9000), Frames: 1},
+				{Command: readBinary, Response: apdu.NewRapdu([]byte{0x01, 0x02}, 0x9000), Frames: 1},
+			},
+		},
+		{
+			name: "GET RESPONSE folded",
+			frames: []apdu.TaggedFrame{
+				frame(apdu.ToCard, "00B0000000"),
+				frame(apdu.FromCard, "016102"),
+				frame(apdu.ToCard, "00C0000002"),
+				frame(apdu.FromCard, "02039000"),
+				frame(apdu.ToCard, "00A4000C023F00"),
+				frame(apdu.FromCard, "9000"),
+			},
+			want: []apdu.ExchangeRecord{
+				{Command: readBinary, Response: apdu.NewRapdu([]byte{0x01, 0x02, 0x03}, 0x9000), Frames: 2},
+				{Command: selectMF, Response: apdu.NewRapdu(nil, 0x9000), Frames: 1},
+			},
+		},
+		{
+			name: "error: unmatched command at end",
+			frames: []apdu.TaggedFrame{
+				frame(apdu.ToCard, "00B0000000"),
+			},
+			wantErr: true,
+		},
+		{
+			name: "error: response without command",
+			frames: []apdu.TaggedFrame{
+				frame(apdu.FromCard, "9000"),
+			},
+			wantErr: true,
+		},
+		{
+			name: "error: consecutive commands",
+			frames: []apdu.TaggedFrame{
+				frame(apdu.ToCard, "00B0000000"),
+				frame(apdu.ToCard, "00B0000000"),
+			},
+			wantErr: true,
+		},
+		{
+			name: "error: invalid direction",
+			frames: []apdu.TaggedFrame{
+				{Data: []byte{0x90, 0x00}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "error: invalid command",
+			frames: []apdu.TaggedFrame{
+				frame(apdu.ToCard, "00B0"),
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := apdu.PairExchanges(tt.frames)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("PairExchanges() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PairExchanges() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExchangeRecord_Continuations(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		frames int
+		want   int
+	}{
+		{
+			name: "zero value",
+		},
+		{
+			name:   "single frame",
+			frames: 1,
+		},
+		{
+			name:   "GET RESPONSE round trips",
+			frames: 3,
+			want:   2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := (apdu.ExchangeRecord{Frames: tt.frames}).Continuations(); got != tt.want {
+				t.Errorf("Continuations() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExchangeRecord_String(t *testing.T) {
+	t.Parallel()
+
+	e := apdu.ExchangeRecord{
+		Command:  apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Ne: 256},
+		Response: apdu.NewRapdu([]byte{0x01, 0x02}, 0x9000),
+		Frames:   2,
+	}
+
+	if got, want := e.String(), "00B0000000 => 01029000"; got != want {
+		t.Errorf("String() got = %v, want %v", got, want)
+	}
+}
+
+func TestExchangeRecord_LogValue(t *testing.T) {
+	t.Parallel()
+
+	e := apdu.ExchangeRecord{
+		Command:  apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Ne: 256},
+		Response: apdu.NewRapdu([]byte{0x01, 0x02}, 0x9000),
+		Frames:   2,
+	}
+
+	var buf bytes.Buffer
+	slog.New(slog.NewTextHandler(&buf, nil)).Info("exchange", "exchange", e)
+
+	for _, want := range []string{"exchange.command.info=\"00 B0 00 00 (256)\"", "exchange.response.status=9000", "exchange.response.data=0102", "exchange.frames=2", "exchange.continuations=1"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("LogValue() output %q does not contain %q", buf.String(), want)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package apdu
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	// InsInitializeUpdate defines the INS byte of the GlobalPlatform INITIALIZE UPDATE command.
+	InsInitializeUpdate = 0x50
+	// InsExternalAuthenticate defines the INS byte of the GlobalPlatform EXTERNAL AUTHENTICATE command.
+	InsExternalAuthenticate = 0x82
+	// InsStoreData defines the INS byte of the GlobalPlatform STORE DATA command.
+	InsStoreData = 0xE2
+	// InsGetStatus defines the INS byte of the GlobalPlatform GET STATUS command.
+	InsGetStatus = 0xF2
+	// LenHostChallenge defines the length of the SCP02/SCP03 host challenge and host cryptogram.
+	LenHostChallenge = 8
+)
+
+// InitializeUpdate returns a GlobalPlatform INITIALIZE UPDATE command (80 50) starting a secure channel session with
+// the key version keyVersion (0 for the first available) and the 8 byte hostChallenge.
+func InitializeUpdate(keyVersion byte, hostChallenge []byte) (Capdu, error) {
+	if len(hostChallenge) != LenHostChallenge {
+		return Capdu{}, fmt.Errorf("%s: invalid host challenge length %d - must be %d", packageTag, len(hostChallenge), LenHostChallenge)
+	}
+
+	return Capdu{CLA: 0x80, INS: InsInitializeUpdate, P1: keyVersion, P2: 0x00, Data: hostChallenge, Ne: MaxLenResponseDataStandard}, nil
+}
+
+// ExternalAuthenticate returns a GlobalPlatform EXTERNAL AUTHENTICATE command (84 82) with the securityLevel in P1 and
+// the 8 byte hostCryptogram as data. The CLA indicates secure messaging, the C-MAC must still be appended by the
+// secure channel implementation.
+func ExternalAuthenticate(securityLevel byte, hostCryptogram []byte) (Capdu, error) {
+	if len(hostCryptogram) != LenHostChallenge {
+		return Capdu{}, fmt.Errorf("%s: invalid host cryptogram length %d - must be %d", packageTag, len(hostCryptogram), LenHostChallenge)
+	}
+
+	return Capdu{CLA: 0x84, INS: InsExternalAuthenticate, P1: securityLevel, P2: 0x00, Data: hostCryptogram}, nil
+}
+
+// StoreData returns a GlobalPlatform STORE DATA command (80 E2) for one block of a STORE DATA sequence. P1 b8 is set
+// for the last block, the remaining P1 bits (b7-b6 encryption information, b5-b4 data structure) are left 0 for no
+// information given and may be set on the returned Capdu. P2 is the block number, starting at 0 for the first block.
+// data must not be empty.
+func StoreData(blockNumber byte, lastBlock bool, data []byte) (Capdu, error) {
+	if len(data) == 0 || len(data) > MaxLenCommandDataExtended {
+		return Capdu{}, fmt.Errorf("%s: invalid STORE DATA block length %d - must be between 1 and %d", packageTag, len(data), MaxLenCommandDataExtended)
+	}
+
+	var p1 byte
+	if lastBlock {
+		p1 = 0x80
+	}
+
+	return Capdu{CLA: 0x80, INS: InsStoreData, P1: p1, P2: blockNumber, Data: data}, nil
+}
+
+// GET STATUS subsets selected by P1.
+const (
+	// GetStatusISD selects the Issuer Security Domain.
+	GetStatusISD = 0x80
+	// GetStatusApplications selects the Applications, including Security Domains.
+	GetStatusApplications = 0x40
+	// GetStatusLoadFiles selects the Executable Load Files.
+	GetStatusLoadFiles = 0x20
+	// GetStatusLoadFilesAndModules selects the Executable Load Files and their Executable Modules.
+	GetStatusLoadFilesAndModules = 0x10
+)
+
+// GetStatus returns a GlobalPlatform GET STATUS command (80 F2) for the subset in P1, e.g. GetStatusApplications,
+// with the search criteria searchTLV as data. An empty searchTLV is replaced by "4F00" matching any AID. P2 requests
+// the response in TLV format starting with the first occurrence, re-issue the command with P2 0x03 while the status
+// word is '0x6310' (more data available) to fetch the next occurrences.
+func GetStatus(subset byte, searchTLV []byte) Capdu {
+	if len(searchTLV) == 0 {
+		searchTLV = []byte{0x4F, 0x00}
+	}
+
+	return Capdu{CLA: 0x80, INS: InsGetStatus, P1: subset, P2: 0x02, Data: searchTLV, Ne: MaxLenResponseDataStandard}
+}
+
+// DGI is a GlobalPlatform Data Grouping Identifier block as used in STORE DATA command data during personalization.
+type DGI struct {
+	Tag   uint16 // Tag is the two byte Data Grouping Identifier.
+	Value []byte // Value is the content of the block.
+}
+
+// ParseDGI parses the data of a STORE DATA command as a sequence of DGI blocks. Each block consists of the two byte
+// tag and a length of one byte (0x00 to 0xFE) or 0xFF followed by two byte (up to 65535), followed by the value.
+// The returned values alias data.
+func ParseDGI(data []byte) ([]DGI, error) {
+	var dgis []DGI
+
+	for len(data) > 0 {
+		if len(data) < 3 {
+			return nil, fmt.Errorf("%s: truncated DGI header of %d byte", packageTag, len(data))
+		}
+
+		tag := binary.BigEndian.Uint16(data)
+		length := int(data[2])
+		data = data[3:]
+
+		if length == 0xFF {
+			if len(data) < 2 {
+				return nil, fmt.Errorf("%s: truncated length of DGI %04X", packageTag, tag)
+			}
+			length = int(binary.BigEndian.Uint16(data))
+			data = data[2:]
+		}
+
+		if length > len(data) {
+			return nil, fmt.Errorf("%s: length %d of DGI %04X exceeds remaining %d byte", packageTag, length, tag, len(data))
+		}
+
+		dgis = append(dgis, DGI{Tag: tag, Value: data[:length]})
+		data = data[length:]
+	}
+
+	return dgis, nil
+}
+
+var gpStatusDescriptions = map[uint16]string{
+	0x6283: "Card Life Cycle State is CARD_LOCKED",
+	0x6300: "Authentication of host cryptogram failed",
+	0x6310: "More data available",
+	0x6400: "No specific diagnosis",
+	0x6438: "Imported package not available",
+	0x6581: "Memory failure",
+	0x6700: "Wrong length in Lc",
+	0x6881: "Logical channel not supported or is not active",
+	0x6882: "Secure messaging not supported",
+	0x6982: "Security status not satisfied",
+	0x6985: "Conditions of use not satisfied",
+	0x6A80: "Incorrect values in command data",
+	0x6A81: "Function not supported, e.g. card Life Cycle State is CARD_LOCKED",
+	0x6A82: "Application or file not found",
+	0x6A84: "Not enough memory space",
+	0x6A86: "Incorrect P1 P2",
+	0x6A88: "Referenced data not found",
+	0x6D00: "Invalid instruction",
+	0x6E00: "Invalid class",
+	0x9000: "Success",
+	0x9484: "Algorithm not supported",
+	0x9485: "Invalid key check value",
+}
+
+// DescribeGP returns the meaning of the status word of the Rapdu in the context of GlobalPlatform card management
+// commands, e.g. "Imported package not available" for '0x6438'. The meaning may differ from the generic ISO 7816-4
+// one. An empty string is returned for status words without a GlobalPlatform specific description.
+func (r Rapdu) DescribeGP() string {
+	return gpStatusDescriptions[r.SW()]
+}
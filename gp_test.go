@@ -0,0 +1,299 @@
+package apdu_test
+
+import (
+	"github.com/nvx/go-apdu"
+	"reflect"
+	"testing"
+)
+
+func TestInitializeUpdate(t *testing.T) {
+	t.Parallel()
+
+	challenge := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}
+
+	tests := []struct {
+		name       string
+		keyVersion byte
+		challenge  []byte
+		want       string
+		wantErr    bool
+	}{
+		{
+			name:       "first available key",
+			keyVersion: 0x00,
+			challenge:  challenge,
+			want:       "8050000008010203040506070800",
+		},
+		{
+			name:       "key version 0x30",
+			keyVersion: 0x30,
+			challenge:  challenge,
+			want:       "8050300008010203040506070800",
+		},
+		{
+			name:      "error: challenge too short",
+			challenge: challenge[:7],
+			wantErr:   true,
+		},
+		{
+			name:      "error: challenge too long",
+			challenge: append(challenge[:8:8], 0x09),
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := apdu.InitializeUpdate(tt.keyVersion, tt.challenge)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("InitializeUpdate() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+
+			s, err := got.String()
+			if err != nil {
+				t.Fatalf("String() error = %v", err)
+			}
+			if s != tt.want {
+				t.Errorf("InitializeUpdate() got = %v, want %v", s, tt.want)
+			}
+		})
+	}
+}
+
+func TestExternalAuthenticate(t *testing.T) {
+	t.Parallel()
+
+	cryptogram := []byte{0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88}
+
+	got, err := apdu.ExternalAuthenticate(0x01, cryptogram)
+	if err != nil {
+		t.Fatalf("ExternalAuthenticate() error = %v", err)
+	}
+
+	want := apdu.Capdu{CLA: 0x84, INS: 0x82, P1: 0x01, P2: 0x00, Data: cryptogram}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ExternalAuthenticate() got = %v, want %v", got, want)
+	}
+
+	if _, err = apdu.ExternalAuthenticate(0x01, cryptogram[:4]); err == nil {
+		t.Errorf("ExternalAuthenticate() expected error for short cryptogram")
+	}
+}
+
+func TestRapdu_DescribeGP(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		sw   uint16
+		want string
+	}{
+		{
+			name: "success",
+			sw:   0x9000,
+			want: "Success",
+		},
+		{
+			name: "imported package not available",
+			sw:   0x6438,
+			want: "Imported package not available",
+		},
+		{
+			name: "incorrect values in command data",
+			sw:   0x6A80,
+			want: "Incorrect values in command data",
+		},
+		{
+			name: "conditions of use not satisfied",
+			sw:   0x6985,
+			want: "Conditions of use not satisfied",
+		},
+		{
+			name: "unknown",
+			sw:   0x6F00,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := apdu.NewRapdu(nil, tt.sw).DescribeGP(); got != tt.want {
+				t.Errorf("DescribeGP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDGI(t *testing.T) {
+	t.Parallel()
+
+	long := make([]byte, 300)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    []apdu.DGI
+		wantErr bool
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "short length form",
+			data: []byte{0x01, 0x01, 0x03, 0x70, 0x01, 0x00, 0x92, 0x00, 0x00},
+			want: []apdu.DGI{
+				{Tag: 0x0101, Value: []byte{0x70, 0x01, 0x00}},
+				{Tag: 0x9200, Value: []byte{}},
+			},
+		},
+		{
+			name: "long length form",
+			data: append([]byte{0x82, 0x01, 0xFF, 0x01, 0x2C}, long...),
+			want: []apdu.DGI{
+				{Tag: 0x8201, Value: long},
+			},
+		},
+		{
+			name:    "error: truncated header",
+			data:    []byte{0x01, 0x01},
+			wantErr: true,
+		},
+		{
+			name:    "error: truncated long length",
+			data:    []byte{0x01, 0x01, 0xFF, 0x01},
+			wantErr: true,
+		},
+		{
+			name:    "error: truncated value",
+			data:    []byte{0x01, 0x01, 0x03, 0x70, 0x01},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := apdu.ParseDGI(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseDGI() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseDGI() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetStatus(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		subset    byte
+		searchTLV []byte
+		want      string
+	}{
+		{
+			name:   "applications matching any AID",
+			subset: apdu.GetStatusApplications,
+			want:   "80F24002024F0000",
+		},
+		{
+			name:      "issuer security domain",
+			subset:    apdu.GetStatusISD,
+			searchTLV: []byte{0x4F, 0x00},
+			want:      "80F28002024F0000",
+		},
+		{
+			name:      "load files by AID",
+			subset:    apdu.GetStatusLoadFiles,
+			searchTLV: []byte{0x4F, 0x05, 0xA0, 0x00, 0x00, 0x00, 0x03},
+			want:      "80F22002074F05A00000000300",
+		},
+		{
+			name:   "load files and modules",
+			subset: apdu.GetStatusLoadFilesAndModules,
+			want:   "80F21002024F0000",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			s, err := apdu.GetStatus(tt.subset, tt.searchTLV).String()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s != tt.want {
+				t.Errorf("GetStatus() = %s, want %s", s, tt.want)
+			}
+		})
+	}
+}
+
+func TestStoreData(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		blockNumber byte
+		lastBlock   bool
+		data        []byte
+		want        string
+		wantErr     bool
+	}{
+		{
+			name:        "first of more blocks",
+			blockNumber: 0,
+			data:        []byte{0x01, 0x01, 0x01, 0xAA},
+			want:        "80E2000004010101AA",
+		},
+		{
+			name:        "last block",
+			blockNumber: 0x12,
+			lastBlock:   true,
+			data:        []byte{0x92, 0x00, 0x00},
+			want:        "80E2801203920000",
+		},
+		{
+			name:    "error: empty data",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := apdu.StoreData(tt.blockNumber, tt.lastBlock, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("StoreData() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+
+			s, err := got.String()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s != tt.want {
+				t.Errorf("StoreData() = %s, want %s", s, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package apdu
+
+import (
+	"encoding/hex"
+	"strings"
+)
+
+const (
+	hexUpperDigits = "0123456789ABCDEF"
+	hexLowerDigits = "0123456789abcdef"
+)
+
+// normalizeHex strips whitespace and colon separators as well as 0x prefixes of the separated tokens from a hex
+// string, e.g. "0x90 0x00" and "90:00" both become "9000". Remaining characters are left for hex decoding to reject.
+func normalizeHex(s string) string {
+	var sb strings.Builder
+	sb.Grow(len(s))
+
+	for _, token := range strings.FieldsFunc(s, isHexSeparator) {
+		if len(token) >= 2 && token[0] == '0' && (token[1] == 'x' || token[1] == 'X') {
+			token = token[2:]
+		}
+		sb.WriteString(token)
+	}
+
+	return sb.String()
+}
+
+func isHexSeparator(r rune) bool {
+	switch r {
+	case ' ', '\t', '\r', '\n', ':':
+		return true
+	}
+
+	return false
+}
+
+// expandHex encodes the n byte at the end of b as hex using digits in place, b must have capacity for n more byte.
+// The byte are expanded back to front so no source byte is overwritten before it was read.
+func expandHex(b []byte, n int, digits string) []byte {
+	start := len(b) - n
+	b = b[:len(b)+n]
+
+	for i := n - 1; i >= 0; i-- {
+		v := b[start+i]
+		b[start+2*i] = digits[v>>4]
+		b[start+2*i+1] = digits[v&0x0F]
+	}
+
+	return b
+}
+
+// writeHex writes b as hex using digits to sb.
+func writeHex(sb *strings.Builder, b []byte, digits string) {
+	for _, v := range b {
+		sb.WriteByte(digits[v>>4])
+		sb.WriteByte(digits[v&0x0F])
+	}
+}
+
+// FormatBytes returns b as uppercase hex with the byte separated by spaces, e.g. "00 A4 04 00", for logs and display.
+// An empty b returns an empty string.
+func FormatBytes(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.Grow(3*len(b) - 1)
+
+	for i, v := range b {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteByte(hexUpperDigits[v>>4])
+		sb.WriteByte(hexUpperDigits[v&0x0F])
+	}
+
+	return sb.String()
+}
+
+// goStringHex formats b for GoString methods as hex"..." or nil.
+func goStringHex(b []byte) string {
+	if b == nil {
+		return "nil"
+	}
+
+	return `hex"` + strings.ToUpper(hex.EncodeToString(b)) + `"`
+}
+
+// decodeHexString decodes the hex string s into dst, which must have a length of len(s)/2, without converting s to a
+// byte slice first. Errors are the same hex.InvalidByteError hex.DecodeString returns.
+func decodeHexString(dst []byte, s string) error {
+	for i := range dst {
+		hi, ok := fromHexChar(s[2*i])
+		if !ok {
+			return hex.InvalidByteError(s[2*i])
+		}
+
+		lo, ok := fromHexChar(s[2*i+1])
+		if !ok {
+			return hex.InvalidByteError(s[2*i+1])
+		}
+
+		dst[i] = hi<<4 | lo
+	}
+
+	return nil
+}
+
+func fromHexChar(c byte) (byte, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', true
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10, true
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10, true
+	}
+
+	return 0, false
+}
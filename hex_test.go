@@ -0,0 +1,40 @@
+package apdu_test
+
+import (
+	"github.com/nvx/go-apdu"
+	"testing"
+)
+
+func TestFormatBytes(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		b    []byte
+		want string
+	}{
+		{name: "command header", b: []byte{0x00, 0xA4, 0x04, 0x00}, want: "00 A4 04 00"},
+		{name: "single byte", b: []byte{0x9F}, want: "9F"},
+		{name: "empty", b: []byte{}, want: ""},
+		{name: "nil"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := apdu.FormatBytes(tt.b); got != tt.want {
+				t.Errorf("FormatBytes() got = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func BenchmarkFormatBytes(b *testing.B) {
+	data := make([]byte, 256)
+
+	b.ReportAllocs()
+	for b.Loop() {
+		_ = apdu.FormatBytes(data)
+	}
+}
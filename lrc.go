@@ -0,0 +1,32 @@
+package apdu
+
+import "fmt"
+
+// ParseCapduWithLRC parses a Command APDU followed by a one byte LRC, the XOR of all preceding byte, as appended by
+// some serial readers. The LRC is verified and stripped before calling ParseCapdu.
+func ParseCapduWithLRC(b []byte) (Capdu, error) {
+	if len(b) < LenHeader+1 {
+		return Capdu{}, fmt.Errorf("%s: invalid length - Capdu with LRC must consist of at least 5 byte, got %d", packageTag, len(b))
+	}
+
+	frame, lrc := b[:len(b)-1], b[len(b)-1]
+	if want := xorLRC(frame); lrc != want {
+		return Capdu{}, fmt.Errorf("%s: LRC mismatch - got %02X, calculated %02X", packageTag, lrc, want)
+	}
+
+	return ParseCapdu(frame)
+}
+
+// AppendLRC appends the one byte LRC of b, the XOR of all its byte, to b as expected by ParseCapduWithLRC.
+func AppendLRC(b []byte) []byte {
+	return append(b, xorLRC(b))
+}
+
+func xorLRC(b []byte) byte {
+	var lrc byte
+	for _, v := range b {
+		lrc ^= v
+	}
+
+	return lrc
+}
@@ -0,0 +1,83 @@
+package apdu_test
+
+import (
+	"github.com/nvx/go-apdu"
+	"reflect"
+	"testing"
+)
+
+func TestParseCapduWithLRC(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		b       []byte
+		want    apdu.Capdu
+		wantErr bool
+	}{
+		{
+			name: "Case 1",
+			b:    []byte{0x00, 0xA4, 0x04, 0x00, 0xA0},
+			want: apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00},
+		},
+		{
+			name: "Case 4",
+			b:    []byte{0x00, 0xA4, 0x04, 0x00, 0x02, 0x3F, 0x00, 0x00, 0x9D},
+			want: apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, Data: []byte{0x3F, 0x00}, Ne: 256},
+		},
+		{
+			name:    "error: LRC mismatch",
+			b:       []byte{0x00, 0xA4, 0x04, 0x00, 0xA1},
+			wantErr: true,
+		},
+		{
+			name:    "error: too short",
+			b:       []byte{0x00, 0xA4, 0x04, 0x00},
+			wantErr: true,
+		},
+		{
+			name:    "error: invalid Capdu",
+			b:       []byte{0x00, 0xA4, 0x04, 0x00, 0x05, 0x01, 0xA4},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := apdu.ParseCapduWithLRC(tt.b)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseCapduWithLRC() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseCapduWithLRC() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAppendLRC(t *testing.T) {
+	t.Parallel()
+
+	c := apdu.Capdu{CLA: 0x80, INS: 0xCA, P1: 0x9F, P2: 0x7F, Ne: 256}
+	b, err := c.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	framed := apdu.AppendLRC(b)
+	if want := []byte{0x80, 0xCA, 0x9F, 0x7F, 0x00, 0xAA}; !reflect.DeepEqual(framed, want) {
+		t.Errorf("AppendLRC() = %X, want %X", framed, want)
+	}
+
+	got, err := apdu.ParseCapduWithLRC(framed)
+	if err != nil {
+		t.Fatalf("ParseCapduWithLRC() error = %v", err)
+	}
+	if !got.Equal(c) {
+		t.Errorf("ParseCapduWithLRC() got = %v, want %v", got, c)
+	}
+}
@@ -0,0 +1,195 @@
+package apdu
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// CapduFromMap builds a Capdu from a generic map as produced by decoding JSON or YAML, e.g. for templating.
+// The keys are "cla", "ins", "p1", "p2", "data", "ne" and "label", of which "cla" and "ins" are required.
+// Header bytes may be given as numbers or hex strings ("A4" or "0xA4"), data as a hex string (separators are allowed
+// as for ParseRapduHexStringLenient) or byte slice, ne as a number and label as a string. Unknown keys are rejected.
+func CapduFromMap(m map[string]any) (Capdu, error) {
+	var c Capdu
+
+	for _, key := range []string{"cla", "ins"} {
+		if _, ok := m[key]; !ok {
+			return Capdu{}, fmt.Errorf("%s: map field %q missing", packageTag, key)
+		}
+	}
+
+	for key, v := range m {
+		var err error
+
+		switch key {
+		case "cla":
+			c.CLA, err = mapByte(v)
+		case "ins":
+			c.INS, err = mapByte(v)
+		case "p1":
+			c.P1, err = mapByte(v)
+		case "p2":
+			c.P2, err = mapByte(v)
+		case "data":
+			c.Data, err = mapData(v)
+		case "ne":
+			c.Ne, err = mapNe(v)
+		case "label":
+			var ok bool
+			if c.Label, ok = v.(string); !ok {
+				err = fmt.Errorf("expected string, got %T", v)
+			}
+		default:
+			err = fmt.Errorf("unknown field")
+		}
+
+		if err != nil {
+			return Capdu{}, fmt.Errorf("%s: map field %q: %w", packageTag, key, err)
+		}
+	}
+
+	return c, nil
+}
+
+// ToMap returns the Capdu as a generic map in the form accepted by CapduFromMap. Header bytes and data are uppercase
+// hex strings and ne is an int. Data and label are only included if not empty.
+func (c Capdu) ToMap() map[string]any {
+	m := map[string]any{
+		"cla": fmt.Sprintf("%02X", c.CLA),
+		"ins": fmt.Sprintf("%02X", c.INS),
+		"p1":  fmt.Sprintf("%02X", c.P1),
+		"p2":  fmt.Sprintf("%02X", c.P2),
+		"ne":  c.Ne,
+	}
+	if len(c.Data) > 0 {
+		m["data"] = fmt.Sprintf("%X", c.Data)
+	}
+	if c.Label != "" {
+		m["label"] = c.Label
+	}
+
+	return m
+}
+
+func mapByte(v any) (byte, error) {
+	if s, ok := v.(string); ok {
+		s = normalizeHex(s)
+		if len(s) != 2 {
+			return 0, fmt.Errorf("expected one hex encoded byte, got %q", s)
+		}
+
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			return 0, err
+		}
+
+		return b[0], nil
+	}
+
+	i, err := mapInt(v)
+	if err != nil {
+		return 0, err
+	}
+	if i < 0 || i > math.MaxUint8 {
+		return 0, fmt.Errorf("value %d out of byte range", i)
+	}
+
+	return byte(i), nil
+}
+
+func mapData(v any) ([]byte, error) {
+	switch d := v.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		return d, nil
+	case string:
+		b, err := hex.DecodeString(normalizeHex(d))
+		if err != nil {
+			return nil, err
+		}
+		if len(b) == 0 {
+			return nil, nil
+		}
+
+		return b, nil
+	}
+
+	return nil, fmt.Errorf("expected hex string or byte slice, got %T", v)
+}
+
+func mapNe(v any) (int, error) {
+	ne, err := mapInt(v)
+	if err != nil {
+		return 0, err
+	}
+	if ne < 0 || ne > MaxLenResponseDataExtended {
+		return 0, fmt.Errorf("value %d out of range 0 to %d", ne, MaxLenResponseDataExtended)
+	}
+
+	return ne, nil
+}
+
+func mapInt(v any) (int, error) {
+	switch n := v.(type) {
+	case int:
+		return n, nil
+	case int8:
+		return int(n), nil
+	case int16:
+		return int(n), nil
+	case int32:
+		return int(n), nil
+	case int64:
+		return intFromInt64(n)
+	case uint:
+		return intFromUint64(uint64(n))
+	case uint8:
+		return int(n), nil
+	case uint16:
+		return int(n), nil
+	case uint32:
+		return intFromUint64(uint64(n))
+	case uint64:
+		return intFromUint64(n)
+	case float32:
+		return intFromFloat64(float64(n))
+	case float64:
+		return intFromFloat64(n)
+	case json.Number:
+		i, err := n.Int64()
+		if err != nil {
+			return 0, err
+		}
+
+		return intFromInt64(i)
+	}
+
+	return 0, fmt.Errorf("expected number, got %T", v)
+}
+
+func intFromInt64(n int64) (int, error) {
+	if n < math.MinInt32 || n > math.MaxInt32 {
+		return 0, fmt.Errorf("value %d out of range", n)
+	}
+
+	return int(n), nil
+}
+
+func intFromUint64(n uint64) (int, error) {
+	if n > math.MaxInt32 {
+		return 0, fmt.Errorf("value %d out of range", n)
+	}
+
+	return int(n), nil
+}
+
+func intFromFloat64(f float64) (int, error) {
+	if f != math.Trunc(f) || f < math.MinInt32 || f > math.MaxInt32 {
+		return 0, fmt.Errorf("value %v is not an integer in range", f)
+	}
+
+	return int(f), nil
+}
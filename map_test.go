@@ -0,0 +1,116 @@
+package apdu_test
+
+import (
+	"encoding/json"
+	"github.com/nvx/go-apdu"
+	"reflect"
+	"testing"
+)
+
+func TestCapduFromMap(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		m       map[string]any
+		want    apdu.Capdu
+		wantErr bool
+	}{
+		{
+			name: "hex strings",
+			m:    map[string]any{"cla": "00", "ins": "0xA4", "p1": "04", "p2": "00", "data": "A0 00 00 00 03", "ne": 256},
+			want: apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, Data: []byte{0xA0, 0x00, 0x00, 0x00, 0x03}, Ne: 256},
+		},
+		{
+			name: "JSON numbers",
+			m:    map[string]any{"cla": float64(0x80), "ins": float64(0xCA), "p1": float64(0x9F), "p2": float64(0x7F), "ne": float64(0)},
+			want: apdu.Capdu{CLA: 0x80, INS: 0xCA, P1: 0x9F, P2: 0x7F},
+		},
+		{
+			name: "json.Number, byte slice and label",
+			m:    map[string]any{"cla": json.Number("0"), "ins": uint8(0xD6), "data": []byte{0x01}, "label": "Update"},
+			want: apdu.Capdu{CLA: 0x00, INS: 0xD6, Data: []byte{0x01}, Label: "Update"},
+		},
+		{
+			name:    "error: missing ins",
+			m:       map[string]any{"cla": "00"},
+			wantErr: true,
+		},
+		{
+			name:    "error: unknown field",
+			m:       map[string]any{"cla": "00", "ins": "A4", "le": 0},
+			wantErr: true,
+		},
+		{
+			name:    "error: byte out of range",
+			m:       map[string]any{"cla": 256, "ins": "A4"},
+			wantErr: true,
+		},
+		{
+			name:    "error: hex string too long",
+			m:       map[string]any{"cla": "000", "ins": "A4"},
+			wantErr: true,
+		},
+		{
+			name:    "error: fractional number",
+			m:       map[string]any{"cla": 0.5, "ins": "A4"},
+			wantErr: true,
+		},
+		{
+			name:    "error: invalid data",
+			m:       map[string]any{"cla": "00", "ins": "A4", "data": "GG"},
+			wantErr: true,
+		},
+		{
+			name:    "error: ne out of range",
+			m:       map[string]any{"cla": "00", "ins": "B0", "ne": 65537},
+			wantErr: true,
+		},
+		{
+			name:    "error: ne as string",
+			m:       map[string]any{"cla": "00", "ins": "B0", "ne": "256"},
+			wantErr: true,
+		},
+		{
+			name:    "error: label type",
+			m:       map[string]any{"cla": "00", "ins": "B0", "label": 1},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := apdu.CapduFromMap(tt.m)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CapduFromMap() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CapduFromMap() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCapdu_ToMap(t *testing.T) {
+	t.Parallel()
+
+	c := apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, Data: []byte{0xA0, 0x00}, Ne: 256, Label: "Select"}
+	want := map[string]any{"cla": "00", "ins": "A4", "p1": "04", "p2": "00", "data": "A000", "ne": 256, "label": "Select"}
+
+	got := c.ToMap()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ToMap() got = %v, want %v", got, want)
+	}
+
+	back, err := apdu.CapduFromMap(got)
+	if err != nil {
+		t.Fatalf("CapduFromMap() error = %v", err)
+	}
+	if !reflect.DeepEqual(back, c) {
+		t.Errorf("CapduFromMap() got = %v, want %v", back, c)
+	}
+}
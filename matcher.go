@@ -0,0 +1,67 @@
+package apdu
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+// CapduMatcher matches commands against a pattern, e.g. to select the responder of a mock card.
+type CapduMatcher struct {
+	pattern  []byte
+	wildcard []bool
+}
+
+// NewCapduMatcher returns a CapduMatcher for pattern, which consists of the four header byte CLA, INS, P1 and P2
+// optionally followed by a prefix of the command data, each byte given as two hex digits or "**" matching any value.
+// For example "00A404**" matches any SELECT by DF name and "00A40400A000000003" any SELECT by DF name with P2 0x00 and
+// data starting with A000000003. Ne is not matched. Whitespace and colon separators are ignored as by
+// ParseCapduHexStringLenient.
+func NewCapduMatcher(pattern string) (CapduMatcher, error) {
+	s := normalizeHex(pattern)
+	if len(s)%2 != 0 || len(s) < 2*LenHeader {
+		return CapduMatcher{}, fmt.Errorf("%s: invalid command pattern %q - must consist of at least %d byte", packageTag, pattern, LenHeader)
+	}
+
+	m := CapduMatcher{pattern: make([]byte, len(s)/2), wildcard: make([]bool, len(s)/2)}
+	for i := range m.pattern {
+		pair := s[2*i : 2*i+2]
+		if pair == "**" {
+			m.wildcard[i] = true
+			continue
+		}
+
+		if _, err := hex.Decode(m.pattern[i:i+1], []byte(pair)); err != nil {
+			return CapduMatcher{}, fmt.Errorf("%s: invalid command pattern %q: %w", packageTag, pattern, err)
+		}
+	}
+
+	return m, nil
+}
+
+// Match returns true if the header of c matches the pattern and the data of c starts with the data prefix of the
+// pattern.
+func (m CapduMatcher) Match(c Capdu) bool {
+	if len(m.pattern) > LenHeader+len(c.Data) {
+		return false
+	}
+
+	header := [LenHeader]byte{c.CLA, c.INS, c.P1, c.P2}
+	for i, want := range m.pattern {
+		if m.wildcard[i] {
+			continue
+		}
+
+		var got byte
+		if i < LenHeader {
+			got = header[i]
+		} else {
+			got = c.Data[i-LenHeader]
+		}
+
+		if got != want {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,94 @@
+package apdu_test
+
+import (
+	"github.com/nvx/go-apdu"
+	"testing"
+)
+
+func TestCapduMatcher_Match(t *testing.T) {
+	t.Parallel()
+
+	selectAID := apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, Data: []byte{0xA0, 0x00, 0x00, 0x00, 0x03, 0x10, 0x10}, Ne: 256}
+
+	tests := []struct {
+		name    string
+		pattern string
+		capdu   apdu.Capdu
+		want    bool
+		wantErr bool
+	}{
+		{
+			name:    "exact header",
+			pattern: "00A40400",
+			capdu:   selectAID,
+			want:    true,
+		},
+		{
+			name:    "wildcard P1 P2",
+			pattern: "00A4****",
+			capdu:   apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x00, P2: 0x0C, Data: []byte{0x3F, 0x00}},
+			want:    true,
+		},
+		{
+			name:    "data prefix",
+			pattern: "00 A4 04 ** A0 00 00 00 03",
+			capdu:   selectAID,
+			want:    true,
+		},
+		{
+			name:    "lowercase with data wildcard",
+			pattern: "00a404**a0**0000",
+			capdu:   selectAID,
+			want:    true,
+		},
+		{
+			name:    "header mismatch",
+			pattern: "80A40400",
+			capdu:   selectAID,
+		},
+		{
+			name:    "data mismatch",
+			pattern: "00A40400A000000004",
+			capdu:   selectAID,
+		},
+		{
+			name:    "data shorter than prefix",
+			pattern: "00A40400A0000000031010FF",
+			capdu:   selectAID,
+		},
+		{
+			name:    "error: too short",
+			pattern: "00A404",
+			wantErr: true,
+		},
+		{
+			name:    "error: odd length",
+			pattern: "00A404000",
+			wantErr: true,
+		},
+		{
+			name:    "error: invalid characters",
+			pattern: "00A404*G",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			m, err := apdu.NewCapduMatcher(tt.pattern)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewCapduMatcher() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := m.Match(tt.capdu); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
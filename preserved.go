@@ -0,0 +1,103 @@
+package apdu
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// PreservedCapdu is a Capdu together with the exact bytes it was parsed from, as returned by
+// ParseOptions.ParsePreservedCapdu. Its encoding methods return the parsed bytes as long as the header, Data and Ne
+// of Capdu are unmodified rather than re-encoding, e.g. to replay non-standard encodings exactly. All other methods,
+// e.g. Key and Hash, are called on Capdu and always use the canonical encoding returned by Capdu.Bytes.
+type PreservedCapdu struct {
+	Capdu Capdu // Capdu is the parsed command, its Data aliases a copy of the parsed bytes.
+
+	raw    []byte
+	parsed Capdu
+}
+
+// ParsePreservedCapdu parses a Command APDU according to the options like ParseCapdu and keeps a copy of the parsed
+// bytes, so reusing c afterwards does not affect the returned PreservedCapdu.
+func (o ParseOptions) ParsePreservedCapdu(c []byte) (PreservedCapdu, error) {
+	b := bytes.Clone(c)
+
+	capdu, err := o.ParseCapdu(b)
+	if err != nil {
+		return PreservedCapdu{}, err
+	}
+
+	return PreservedCapdu{Capdu: capdu, raw: b, parsed: capdu}, nil
+}
+
+// preserved returns the parsed bytes if the header, Data and Ne of Capdu are unmodified, otherwise nil.
+func (p PreservedCapdu) preserved() []byte {
+	if p.raw == nil || !p.Capdu.Equal(p.parsed) {
+		return nil
+	}
+
+	return p.raw
+}
+
+// Bytes returns a copy of the parsed bytes if Capdu is unmodified, otherwise the byte representation of Capdu as
+// returned by Capdu.Bytes.
+func (p PreservedCapdu) Bytes() ([]byte, error) {
+	if b := p.preserved(); b != nil {
+		return bytes.Clone(b), nil
+	}
+
+	return p.Capdu.Bytes()
+}
+
+// EncodeInto writes the byte representation as returned by Bytes into buf and returns the number of byte written.
+// Like Capdu.EncodeInto it never allocates, an error is returned if buf is too small.
+func (p PreservedCapdu) EncodeInto(buf []byte) (n int, err error) {
+	b := p.preserved()
+	if b == nil {
+		return p.Capdu.EncodeInto(buf)
+	}
+
+	if len(buf) < len(b) {
+		return 0, fmt.Errorf("%s: buffer of %d byte too small for Capdu of %d byte", packageTag, len(buf), len(b))
+	}
+
+	return copy(buf, b), nil
+}
+
+// Len returns the length of the byte representation as returned by Bytes.
+func (p PreservedCapdu) Len() (int, error) {
+	if b := p.preserved(); b != nil {
+		return len(b), nil
+	}
+
+	return p.Capdu.Len()
+}
+
+// String returns the uppercase hex encoded string representation of the encoding as returned by Bytes.
+func (p PreservedCapdu) String() (string, error) {
+	b := p.preserved()
+	if b == nil {
+		return p.Capdu.String()
+	}
+
+	var sb strings.Builder
+	sb.Grow(2 * len(b))
+	writeHex(&sb, b, hexUpperDigits)
+
+	return sb.String(), nil
+}
+
+// AppendHex appends the uppercase hex-string representation as returned by String to dst and returns the extended
+// slice.
+func (p PreservedCapdu) AppendHex(dst []byte) ([]byte, error) {
+	b := p.preserved()
+	if b == nil {
+		return p.Capdu.AppendHex(dst)
+	}
+
+	dst = slices.Grow(dst, 2*len(b))
+	dst = append(dst, b...)
+
+	return expandHex(dst, len(b), hexUpperDigits), nil
+}
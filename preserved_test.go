@@ -0,0 +1,146 @@
+package apdu_test
+
+import (
+	"bytes"
+	"github.com/nvx/go-apdu"
+	"testing"
+)
+
+func TestParseOptions_ParsePreservedCapdu(t *testing.T) {
+	t.Parallel()
+
+	// standard Lc with an extended Le, canonically encoded in extended form
+	b := []byte{0x00, 0xB0, 0x00, 0x00, 0x02, 0x01, 0x02, 0x01, 0x2C}
+	canonical := []byte{0x00, 0xB0, 0x00, 0x00, 0x00, 0x00, 0x02, 0x01, 0x02, 0x01, 0x2C}
+
+	got, err := apdu.ParseOptions{AllowStandardLcExtendedLe: true}.ParsePreservedCapdu(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encoded, err := got.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(encoded, b) {
+		t.Errorf("Bytes() got = %X, want preserved %X", encoded, b)
+	}
+
+	got.Capdu.Label = "read"
+	if encoded, err = got.Bytes(); err != nil || !bytes.Equal(encoded, b) {
+		t.Errorf("Bytes() after setting Label got = %X, %v, want preserved %X", encoded, err, b)
+	}
+
+	modified := got
+	modified.Capdu.P2 = 0x01
+	want := []byte{0x00, 0xB0, 0x00, 0x01, 0x00, 0x00, 0x02, 0x01, 0x02, 0x01, 0x2C}
+	if encoded, err = modified.Bytes(); err != nil || !bytes.Equal(encoded, want) {
+		t.Errorf("Bytes() after modifying P2 got = %X, %v, want re-encoded %X", encoded, err, want)
+	}
+	if s, err := modified.String(); err != nil || s != "00B000010000020102012C" {
+		t.Errorf("String() after modifying P2 got = %s, %v, want re-encoded %X", s, err, want)
+	}
+
+	modified = got
+	modified.Capdu.Data = []byte{0x01, 0x02}
+	if encoded, err = modified.Bytes(); err != nil || !bytes.Equal(encoded, b) {
+		t.Errorf("Bytes() after replacing Data with equal content got = %X, %v, want preserved %X", encoded, err, b)
+	}
+
+	if encoded, err = got.Capdu.Bytes(); err != nil || !bytes.Equal(encoded, canonical) {
+		t.Errorf("Capdu.Bytes() got = %X, %v, want re-encoded %X", encoded, err, canonical)
+	}
+
+	if s, err := got.String(); err != nil || s != "00B00000020102012C" {
+		t.Errorf("String() got = %s, %v, want preserved %X", s, err, b)
+	}
+	if h, err := got.AppendHex([]byte("> ")); err != nil || string(h) != "> 00B00000020102012C" {
+		t.Errorf("AppendHex() got = %s, %v, want preserved %X", h, err, b)
+	}
+	if n, err := got.Len(); err != nil || n != len(b) {
+		t.Errorf("Len() got = %d, %v, want %d", n, err, len(b))
+	}
+	buf := make([]byte, len(b))
+	if n, err := got.EncodeInto(buf); err != nil || !bytes.Equal(buf[:n], b) {
+		t.Errorf("EncodeInto() got = %X, %v, want preserved %X", buf[:n], err, b)
+	}
+	if _, err := got.EncodeInto(buf[:len(b)-1]); err == nil {
+		t.Errorf("EncodeInto() expected error for too small buffer")
+	}
+}
+
+func TestParseOptions_ParsePreservedCapduInvalid(t *testing.T) {
+	t.Parallel()
+
+	if _, err := (apdu.ParseOptions{}).ParsePreservedCapdu([]byte{0x00, 0xB0, 0x00, 0x00, 0x02, 0x01, 0x02, 0x01, 0x2C}); err == nil {
+		t.Errorf("ParsePreservedCapdu() expected error for mixed encoding without AllowStandardLcExtendedLe")
+	}
+}
+
+func TestParseOptions_ParsePreservedCapduBufferReuse(t *testing.T) {
+	t.Parallel()
+
+	buf := []byte{0x00, 0xB0, 0x00, 0x00, 0x10}
+
+	got, err := apdu.ParseOptions{}.ParsePreservedCapdu(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the caller reuses its read buffer for the next command
+	copy(buf, []byte{0x00, 0xD6, 0x00, 0x00, 0x10})
+
+	encoded, err := got.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{0x00, 0xB0, 0x00, 0x00, 0x10}; !bytes.Equal(encoded, want) {
+		t.Errorf("Bytes() got = %X, want %X", encoded, want)
+	}
+}
+
+func TestPreservedCapdu_CanonicalCapdu(t *testing.T) {
+	t.Parallel()
+
+	b := []byte{0x00, 0xB0, 0x00, 0x00, 0x02, 0x01, 0x02, 0x01, 0x2C}
+	got, err := apdu.ParseOptions{AllowStandardLcExtendedLe: true}.ParsePreservedCapdu(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	twin := apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Data: []byte{0x01, 0x02}, Ne: 300}
+	if !got.Capdu.Equal(twin) {
+		t.Fatalf("Equal() got = false, want true")
+	}
+
+	gotKey, err := got.Capdu.Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantKey, err := twin.Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotKey != wantKey {
+		t.Errorf("Key() got = %s, want %s of the canonical twin", gotKey, wantKey)
+	}
+
+	gotHash, err := got.Capdu.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantHash, err := twin.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotHash != wantHash {
+		t.Errorf("Hash() got = %X, want %X of the canonical twin", gotHash, wantHash)
+	}
+
+	if !got.Capdu.SelfConsistent() {
+		t.Errorf("SelfConsistent() got = false, want true")
+	}
+	if le, err := got.Capdu.EncodedLe(); err != nil || !bytes.Equal(le, []byte{0x01, 0x2C}) {
+		t.Errorf("EncodedLe() got = %X, %v, want 012C", le, err)
+	}
+}
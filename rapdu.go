@@ -1,6 +1,8 @@
 package apdu
 
 import (
+	"bytes"
+	"encoding/binary"
 	"encoding/hex"
 	"fmt"
 	"log/slog"
@@ -23,16 +25,51 @@ type Rapdu struct {
 	SW2  byte   // SW2 is the second byte of a status word.
 }
 
+// NewRapdu returns a Rapdu with the given data and the status word sw split into SW1 and SW2.
+func NewRapdu(data []byte, sw uint16) Rapdu {
+	return Rapdu{Data: data, SW1: byte(sw >> 8), SW2: byte(sw)}
+}
+
 func (r Rapdu) SW() uint16 {
 	return uint16(r.SW1)<<8 | uint16(r.SW2)
 }
 
+// GoString implements fmt.GoStringer for legible %#v output, e.g. apdu.Rapdu{Data:hex"0102", SW1:0x90, SW2:0x00}.
+func (r Rapdu) GoString() string {
+	return fmt.Sprintf("apdu.Rapdu{Data:%s, SW1:0x%02X, SW2:0x%02X}", goStringHex(r.Data), r.SW1, r.SW2)
+}
+
 func (r Rapdu) LogValue() slog.Value {
 	return slog.GroupValue(slog.String("status", fmt.Sprintf("%04X", r.SW())), slog.String("data", fmt.Sprintf("%X", r.Data)))
 }
 
+// RapduParseOptions configures how a Response APDU is parsed. The zero value accepts any status word.
+type RapduParseOptions struct {
+	// RejectInvalidSW returns an error for a status word that is not IsValid, e.g. '0x0000' as read from a transport
+	// after a framing error or a dropped response.
+	RejectInvalidSW bool
+}
+
 // ParseRapdu parses a Response APDU and returns a Rapdu.
-func ParseRapdu(b []byte) (_ Rapdu, err error) {
+func ParseRapdu(b []byte) (Rapdu, error) {
+	return RapduParseOptions{}.ParseRapdu(b)
+}
+
+// ParseRapdu parses a Response APDU according to the options and returns a Rapdu.
+func (o RapduParseOptions) ParseRapdu(b []byte) (Rapdu, error) {
+	r, err := parseRapdu(b)
+	if err != nil {
+		return Rapdu{}, err
+	}
+
+	if o.RejectInvalidSW && !r.IsValid() {
+		return Rapdu{}, fmt.Errorf("%s: invalid status word %04X", packageTag, r.SW())
+	}
+
+	return r, nil
+}
+
+func parseRapdu(b []byte) (Rapdu, error) {
 	if len(b) < LenResponseTrailer || len(b) > MaxLenResponseDataExtended+LenResponseTrailer {
 		return Rapdu{}, fmt.Errorf("%s: invalid length - a RAPDU must consist of at least 2 byte and maximum of 65538 byte, got %d", packageTag, len(b))
 	}
@@ -44,6 +81,35 @@ func ParseRapdu(b []byte) (_ Rapdu, err error) {
 	return Rapdu{Data: b[:len(b)-LenResponseTrailer], SW1: b[len(b)-2], SW2: b[len(b)-1]}, nil
 }
 
+// ParseRapduExpectLen calls ParseRapdu and additionally returns an error if the data field is shorter than minData.
+// The parsed Rapdu is returned along with that error, so the status word of a short response can be inspected.
+func ParseRapduExpectLen(b []byte, minData int) (Rapdu, error) {
+	r, err := ParseRapdu(b)
+	if err != nil {
+		return Rapdu{}, err
+	}
+
+	if len(r.Data) < minData {
+		return r, fmt.Errorf("%s: invalid length - expected at least %d byte of response data, got %d", packageTag, minData, len(r.Data))
+	}
+
+	return r, nil
+}
+
+// ParseRapduLengthPrefixed parses a Response APDU framed with a big-endian two byte prefix holding the length of the
+// response data, as used by some CCID readers, and returns a Rapdu. The prefix must match the length of the data.
+func ParseRapduLengthPrefixed(b []byte) (Rapdu, error) {
+	if len(b) < 2+LenResponseTrailer {
+		return Rapdu{}, fmt.Errorf("%s: invalid length - a length prefixed RAPDU must consist of at least 4 byte, got %d", packageTag, len(b))
+	}
+
+	if l := int(binary.BigEndian.Uint16(b)); l != len(b)-2-LenResponseTrailer {
+		return Rapdu{}, fmt.Errorf("%s: invalid length prefix %d - got %d byte of response data", packageTag, l, len(b)-2-LenResponseTrailer)
+	}
+
+	return ParseRapdu(b[2:])
+}
+
 // ParseRapduHexString decodes the hex-string representation of a Response APDU, calls ParseRapdu and returns a Rapdu.
 func ParseRapduHexString(s string) (Rapdu, error) {
 	if len(s)%2 != 0 {
@@ -62,6 +128,12 @@ func ParseRapduHexString(s string) (Rapdu, error) {
 	return ParseRapdu(b)
 }
 
+// ParseRapduHexStringLenient calls ParseRapduHexString after removing whitespace and colon separators as well as 0x
+// prefixes, e.g. "90 00", "01:02:90:00" or "0x90 0x00". Upper and lower case hex digits are accepted.
+func ParseRapduHexStringLenient(s string) (Rapdu, error) {
+	return ParseRapduHexString(normalizeHex(s))
+}
+
 // Bytes returns the byte representation of the RAPDU.
 func (r Rapdu) Bytes() ([]byte, error) {
 	if len(r.Data) > MaxLenResponseDataExtended {
@@ -75,6 +147,13 @@ func (r Rapdu) Bytes() ([]byte, error) {
 	return b, nil
 }
 
+// FitsIFS returns true if the RAPDU including the status word fits into the information field of a single T=1 block
+// of size ifs, otherwise the block layer chains it over multiple I-blocks. This is unrelated to GET RESPONSE at the
+// APDU level. Use NewRapdu with Ne byte of data to check the largest response expected for a command.
+func (r Rapdu) FitsIFS(ifs int) bool {
+	return len(r.Data)+LenResponseTrailer <= ifs
+}
+
 // String calls Bytes and returns the hex encoded string representation of the RAPDU.
 func (r Rapdu) String() (string, error) {
 	b, err := r.Bytes()
@@ -85,6 +164,155 @@ func (r Rapdu) String() (string, error) {
 	return strings.ToUpper(hex.EncodeToString(b)), nil
 }
 
+// StringLower returns the hex encoded string representation of the RAPDU like String, but with lowercase hex digits.
+// The hex digits are written straight into the string, so only the string itself is allocated.
+func (r Rapdu) StringLower() (string, error) {
+	if len(r.Data) > MaxLenResponseDataExtended {
+		return "", fmt.Errorf("%s: len of Rapdu.Data %d exceeds maximum allowed length of %d", packageTag, len(r.Data), MaxLenResponseDataExtended)
+	}
+
+	var sb strings.Builder
+	sb.Grow(2 * (len(r.Data) + LenResponseTrailer))
+
+	sw := [LenResponseTrailer]byte{r.SW1, r.SW2}
+	writeHex(&sb, r.Data, hexLowerDigits)
+	writeHex(&sb, sw[:], hexLowerDigits)
+
+	return sb.String(), nil
+}
+
+// DataTrimmed returns the data of the RAPDU with all trailing pad byte removed, e.g. 0x00 or 0xFF as appended by
+// some cards up to a block boundary. Only trailing pad byte are removed, pad byte within the data are kept. The
+// returned slice aliases the Data of the Rapdu.
+func (r Rapdu) DataTrimmed(pad byte) []byte {
+	data := r.Data
+	for len(data) > 0 && data[len(data)-1] == pad {
+		data = data[:len(data)-1]
+	}
+
+	return data
+}
+
+// EqualTrimmed returns true if both RAPDUs have the same status word and the same data after removing trailing pad
+// byte with DataTrimmed, otherwise false.
+func (r Rapdu) EqualTrimmed(other Rapdu, pad byte) bool {
+	return r.SW() == other.SW() && bytes.Equal(r.DataTrimmed(pad), other.DataTrimmed(pad))
+}
+
+// SplitForT0 splits the Rapdu into responses of at most chunk byte of data as a T=0 card returns them to consecutive
+// GET RESPONSE commands. All but the last response have the status word '0x61xx' with xx the number of remaining byte
+// (0x00 for 256 or more), the last one keeps the status word of the Rapdu. chunk is capped at 256, values below 1 are
+// treated as 256. The returned Data alias the Data of the Rapdu.
+func (r Rapdu) SplitForT0(chunk int) []Rapdu {
+	if chunk < 1 || chunk > MaxLenResponseDataStandard {
+		chunk = MaxLenResponseDataStandard
+	}
+
+	var parts []Rapdu
+	data := r.Data
+
+	for len(data) > chunk {
+		remaining := len(data) - chunk
+		parts = append(parts, Rapdu{Data: data[:chunk], SW1: 0x61, SW2: byte(min(remaining, MaxLenResponseDataStandard))})
+		data = data[chunk:]
+	}
+
+	return append(parts, Rapdu{Data: data, SW1: r.SW1, SW2: r.SW2})
+}
+
+// TruncateToNe returns a copy of the Rapdu with the data truncated to ne byte and the status word preserved, as a card
+// never returns more data than requested, e.g. in a card emulator. ne is the decoded number of expected byte like
+// Capdu.Ne, so an Le of 0x00 is passed as 256 (standard) or 65536 (extended), and an ne of 0 or less truncates all
+// data. The returned Data aliases the Data of the Rapdu. Use TruncateToNeWithSW to signal the truncation.
+func (r Rapdu) TruncateToNe(ne int) Rapdu {
+	r, _ = r.truncateToNe(ne)
+	return r
+}
+
+// TruncateToNeWithSW calls TruncateToNe and additionally replaces the status word with sw if data was truncated, e.g.
+// with the warning '0x6282' (end of file or record reached before reading Ne byte) or a proprietary status word.
+func (r Rapdu) TruncateToNeWithSW(ne int, sw uint16) Rapdu {
+	r, truncated := r.truncateToNe(ne)
+	if truncated {
+		r.SW1, r.SW2 = byte(sw>>8), byte(sw)
+	}
+
+	return r
+}
+
+func (r Rapdu) truncateToNe(ne int) (Rapdu, bool) {
+	ne = max(ne, 0)
+	if len(r.Data) <= ne {
+		return r, false
+	}
+
+	r.Data = r.Data[:ne:ne]
+	if ne == 0 {
+		r.Data = nil
+	}
+
+	return r, true
+}
+
+// Family returns the status word of the Rapdu with the variable part zeroed for the status word families carrying a
+// count, e.g. as low cardinality metrics label:
+//   - '0x61xx' (xx byte still available) is folded to 0x6100
+//   - '0x6Cxx' (wrong Le, xx is the exact length) is folded to 0x6C00
+//   - '0x63Cx' (counter x) is folded to 0x63C0
+//   - '0x9Fxx' (UICC, xx byte available) is folded to 0x9F00
+//
+// Any other status word is returned unchanged.
+func (r Rapdu) Family() uint16 {
+	switch sw := r.SW(); {
+	case r.SW1 == 0x61 || r.SW1 == 0x6C || r.SW1 == 0x9F:
+		return sw & 0xFF00
+	case sw&0xFFF0 == 0x63C0:
+		return 0x63C0
+	default:
+		return sw
+	}
+}
+
+// MatchPattern returns true if the status word of the Rapdu matches pattern, which must consist of exactly 4 hex digits
+// or wildcards, where 'X' or '?' (case-insensitive) matches any nibble, e.g. "9000", "61XX" or "6A8?".
+func (r Rapdu) MatchPattern(pattern string) (bool, error) {
+	if len(pattern) != 4 {
+		return false, fmt.Errorf("%s: invalid status word pattern %q - must consist of 4 characters", packageTag, pattern)
+	}
+
+	sw := r.SW()
+	match := true
+
+	for i := range len(pattern) {
+		var want byte
+		switch ch := pattern[i]; {
+		case ch == 'X' || ch == 'x' || ch == '?':
+			continue
+		case ch >= '0' && ch <= '9':
+			want = ch - '0'
+		case ch >= 'A' && ch <= 'F':
+			want = ch - 'A' + 10
+		case ch >= 'a' && ch <= 'f':
+			want = ch - 'a' + 10
+		default:
+			return false, fmt.Errorf("%s: invalid character %q in status word pattern %q", packageTag, ch, pattern)
+		}
+
+		if byte(sw>>(12-4*i))&0x0F != want {
+			match = false
+		}
+	}
+
+	return match, nil
+}
+
+// IsValid returns true if the status word of the RAPDU is structurally possible, otherwise false. As defined in
+// ISO 7816-3 SW1 must be '0x6X' or '0x9X', with '0x60' being the NULL procedure byte. Any other SW1, including the
+// all-zero status word '0x0000' which usually indicates a framing error or a dropped response, is impossible.
+func (r Rapdu) IsValid() bool {
+	return (r.SW1 > 0x60 && r.SW1 <= 0x6F) || r.SW1&0xF0 == 0x90
+}
+
 // IsSuccess returns true if the RAPDU indicates the successful execution of a command ('0x61xx' or '0x9000'), otherwise false.
 func (r Rapdu) IsSuccess() bool {
 	return r.SW1 == 0x61 || (r.SW() == 0x9000)
@@ -99,3 +327,73 @@ func (r Rapdu) IsWarning() bool {
 func (r Rapdu) IsError() bool {
 	return (r.SW1 == 0x64 || r.SW1 == 0x65) || (r.SW1 >= 0x67 && r.SW1 <= 0x6F)
 }
+
+// IsDataCorrupted returns true if the RAPDU indicates that part of the returned data may be corrupted ('0x6281'), otherwise false.
+func (r Rapdu) IsDataCorrupted() bool {
+	return r.SW() == 0x6281
+}
+
+// IsEOFReached returns true if the RAPDU indicates that the end of file or record was reached before reading Ne bytes ('0x6282'), otherwise false.
+func (r Rapdu) IsEOFReached() bool {
+	return r.SW() == 0x6282
+}
+
+// IsDeactivated returns true if the RAPDU indicates that the selected file is deactivated ('0x6283'), otherwise false.
+func (r Rapdu) IsDeactivated() bool {
+	return r.SW() == 0x6283
+}
+
+// UICCBytesAvailable returns the number of response byte available to fetch with GET RESPONSE indicated by a '0x9Fxx'
+// status word as used by GSM/UICC commands, analogous to the ISO '0x61xx'. An SW2 of 0x00 indicates 256 byte.
+// ok is false for other status words.
+func (r Rapdu) UICCBytesAvailable() (n int, ok bool) {
+	if r.SW1 != 0x9F {
+		return 0, false
+	}
+
+	if r.SW2 == 0x00 {
+		return MaxLenResponseDataStandard, true
+	}
+
+	return int(r.SW2), true
+}
+
+// WrongLengthHint returns the length hinted by a non-standard '0x67xx' status word as returned by some cards to
+// indicate the expected length, e.g. to retry with a corrected length. '0x6700' is the ISO wrong length error without
+// a hint, ok is false for it and other status words. IsError is unaffected.
+func (r Rapdu) WrongLengthHint() (n int, ok bool) {
+	if r.SW1 != 0x67 || r.SW2 == 0x00 {
+		return 0, false
+	}
+
+	return int(r.SW2), true
+}
+
+// HasUsableData returns true if the RAPDU carries response data and indicates success or a warning, otherwise false.
+// Warnings ('0x62xx' and '0x63xx') such as '0x6282' (end of file reached) do not invalidate the returned data.
+func (r Rapdu) HasUsableData() bool {
+	return len(r.Data) > 0 && (r.IsSuccess() || r.IsWarning())
+}
+
+// SameCategory returns true if both RAPDUs indicate success, both a warning or both an error according to IsSuccess,
+// IsWarning and IsError, e.g. to group responses in a report. A '0x66xx' status word, reserved for security-related
+// issues by ISO 7816-4 and not covered by IsError, is treated as an error. Any other status word in neither category,
+// e.g. a proprietary '0x9Fxx', is not in the same category as any status word, including itself.
+func SameCategory(a, b Rapdu) bool {
+	ca, cb := a.category(), b.category()
+	return ca != 0 && ca == cb
+}
+
+// category returns 1 for success, 2 for warning, 3 for error or 0 if uncategorized, see SameCategory.
+func (r Rapdu) category() int {
+	switch {
+	case r.IsSuccess():
+		return 1
+	case r.IsWarning():
+		return 2
+	case r.IsError() || r.SW1 == 0x66:
+		return 3
+	default:
+		return 0
+	}
+}
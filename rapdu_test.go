@@ -1,11 +1,50 @@
 package apdu_test
 
 import (
+	"bytes"
+	"fmt"
 	"github.com/nvx/go-apdu"
 	"reflect"
 	"testing"
 )
 
+func TestNewRapdu(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		data []byte
+		sw   uint16
+		want apdu.Rapdu
+	}{
+		{
+			name: "trailer only",
+			sw:   0x9000,
+			want: apdu.Rapdu{SW1: 0x90, SW2: 0x00},
+		},
+		{
+			name: "trailer and data",
+			data: []byte{0x01, 0x02},
+			sw:   0x6282,
+			want: apdu.Rapdu{Data: []byte{0x01, 0x02}, SW1: 0x62, SW2: 0x82},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := apdu.NewRapdu(tt.data, tt.sw)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewRapdu() got = %v, want %v", got, tt.want)
+			}
+			if got.SW() != tt.sw {
+				t.Errorf("SW() got = %04X, want %04X", got.SW(), tt.sw)
+			}
+		})
+	}
+}
+
 func TestParseRapdu(t *testing.T) {
 	t.Parallel()
 
@@ -60,6 +99,160 @@ func TestParseRapdu(t *testing.T) {
 	}
 }
 
+func TestParseRapduMaxLength(t *testing.T) {
+	t.Parallel()
+
+	b := make([]byte, apdu.MaxLenResponseDataExtended+apdu.LenResponseTrailer)
+	for i := range b {
+		b[i] = byte(i % 251)
+	}
+	b[len(b)-2], b[len(b)-1] = 0x90, 0x00
+
+	r, err := apdu.ParseRapdu(b)
+	if err != nil {
+		t.Fatalf("ParseRapdu() error = %v", err)
+	}
+	if len(r.Data) != apdu.MaxLenResponseDataExtended {
+		t.Fatalf("ParseRapdu() len(Data) = %d, want %d", len(r.Data), apdu.MaxLenResponseDataExtended)
+	}
+	if !bytes.Equal(r.Data, b[:apdu.MaxLenResponseDataExtended]) {
+		t.Errorf("ParseRapdu() Data differs, last byte = %02X, want %02X", r.Data[len(r.Data)-1], b[apdu.MaxLenResponseDataExtended-1])
+	}
+	if r.SW() != 0x9000 {
+		t.Errorf("ParseRapdu() SW = %04X, want 9000", r.SW())
+	}
+
+	got, err := r.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes() error = %v", err)
+	}
+	if !bytes.Equal(got, b) {
+		t.Errorf("Bytes() does not reproduce the %d byte response, got %d byte", len(b), len(got))
+	}
+
+	if _, err = apdu.ParseRapdu(append(b, 0x00)); err == nil {
+		t.Errorf("ParseRapdu() of %d byte expected error", len(b)+1)
+	}
+}
+
+func TestParseRapduExpectLen(t *testing.T) {
+	t.Parallel()
+
+	type args struct {
+		b       []byte
+		minData int
+	}
+
+	tests := []struct {
+		name    string
+		args    args
+		want    apdu.Rapdu
+		wantErr bool
+	}{
+		{
+			name:    "error: trailer only when data expected",
+			args:    args{b: []byte{0x6A, 0x82}, minData: 1},
+			want:    apdu.Rapdu{SW1: 0x6A, SW2: 0x82},
+			wantErr: true,
+		},
+		{
+			name:    "error: data too short",
+			args:    args{b: []byte{0x01, 0x02, 0x62, 0x82}, minData: 3},
+			want:    apdu.Rapdu{Data: []byte{0x01, 0x02}, SW1: 0x62, SW2: 0x82},
+			wantErr: true,
+		},
+		{
+			name:    "error: invalid length",
+			args:    args{b: []byte{0x90}, minData: 0},
+			wantErr: true,
+		},
+		{
+			name: "trailer only when no data expected",
+			args: args{b: []byte{0x90, 0x00}, minData: 0},
+			want: apdu.Rapdu{SW1: 0x90, SW2: 0x00},
+		},
+		{
+			name: "exact data length",
+			args: args{b: []byte{0x01, 0x02, 0x03, 0x90, 0x00}, minData: 3},
+			want: apdu.Rapdu{Data: []byte{0x01, 0x02, 0x03}, SW1: 0x90, SW2: 0x00},
+		},
+		{
+			name: "more data than expected",
+			args: args{b: []byte{0x01, 0x02, 0x03, 0x90, 0x00}, minData: 2},
+			want: apdu.Rapdu{Data: []byte{0x01, 0x02, 0x03}, SW1: 0x90, SW2: 0x00},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := apdu.ParseRapduExpectLen(tt.args.b, tt.args.minData)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseRapduExpectLen() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseRapduExpectLen() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRapduLengthPrefixed(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		b       []byte
+		want    apdu.Rapdu
+		wantErr bool
+	}{
+		{
+			name: "trailer only",
+			b:    []byte{0x00, 0x00, 0x90, 0x00},
+			want: apdu.Rapdu{SW1: 0x90, SW2: 0x00},
+		},
+		{
+			name: "trailer and data",
+			b:    []byte{0x00, 0x03, 0x01, 0x02, 0x03, 0x90, 0x00},
+			want: apdu.Rapdu{Data: []byte{0x01, 0x02, 0x03}, SW1: 0x90, SW2: 0x00},
+		},
+		{
+			name:    "error: length prefix too big",
+			b:       []byte{0x00, 0x04, 0x01, 0x02, 0x03, 0x90, 0x00},
+			wantErr: true,
+		},
+		{
+			name:    "error: length prefix too small",
+			b:       []byte{0x00, 0x02, 0x01, 0x02, 0x03, 0x90, 0x00},
+			wantErr: true,
+		},
+		{
+			name:    "error: too short",
+			b:       []byte{0x00, 0x00, 0x90},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := apdu.ParseRapduLengthPrefixed(tt.b)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseRapduLengthPrefixed() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseRapduLengthPrefixed() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestParseRapduHexString(t *testing.T) {
 	t.Parallel()
 
@@ -119,6 +312,74 @@ func TestParseRapduHexString(t *testing.T) {
 	}
 }
 
+func TestParseRapduHexStringLenient(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		s       string
+		want    apdu.Rapdu
+		wantErr bool
+	}{
+		{
+			name: "strict form",
+			s:    "0102039000",
+			want: apdu.Rapdu{Data: []byte{0x01, 0x02, 0x03}, SW1: 0x90, SW2: 0x00},
+		},
+		{
+			name: "spaces",
+			s:    " 90 00 ",
+			want: apdu.Rapdu{SW1: 0x90, SW2: 0x00},
+		},
+		{
+			name: "colons and mixed case",
+			s:    "0a:0B:6a:82",
+			want: apdu.Rapdu{Data: []byte{0x0A, 0x0B}, SW1: 0x6A, SW2: 0x82},
+		},
+		{
+			name: "0x prefixes and newlines",
+			s:    "0x01\n0X02\t0x9000",
+			want: apdu.Rapdu{Data: []byte{0x01, 0x02}, SW1: 0x90, SW2: 0x00},
+		},
+		{
+			name:    "error: invalid characters",
+			s:       "01 GG 90 00",
+			wantErr: true,
+		},
+		{
+			name:    "error: invalid separator",
+			s:       "01-90-00",
+			wantErr: true,
+		},
+		{
+			name:    "error: uneven number of hex characters",
+			s:       "0 90 00",
+			wantErr: true,
+		},
+		{
+			name:    "error: too short",
+			s:       "0x90",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := apdu.ParseRapduHexStringLenient(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseRapduHexStringLenient() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseRapduHexStringLenient() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestRapdu_Bytes(t *testing.T) {
 	t.Parallel()
 
@@ -232,6 +493,42 @@ func TestRapdu_String(t *testing.T) {
 	}
 }
 
+func TestRapdu_GoString(t *testing.T) {
+	t.Parallel()
+
+	if got, want := fmt.Sprintf("%#v", apdu.NewRapdu([]byte{0x01, 0xAB}, 0x9000)), `apdu.Rapdu{Data:hex"01AB", SW1:0x90, SW2:0x00}`; got != want {
+		t.Errorf("GoString() = %s, want %s", got, want)
+	}
+	if got, want := fmt.Sprintf("%#v", apdu.NewRapdu(nil, 0x6A82)), `apdu.Rapdu{Data:nil, SW1:0x6A, SW2:0x82}`; got != want {
+		t.Errorf("GoString() = %s, want %s", got, want)
+	}
+}
+
+func TestRapdu_StringLower(t *testing.T) {
+	t.Parallel()
+
+	got, err := apdu.NewRapdu([]byte{0xAB, 0xCD}, 0x6A82).StringLower()
+	if err != nil {
+		t.Fatalf("StringLower() error = %v", err)
+	}
+	if want := "abcd6a82"; got != want {
+		t.Errorf("StringLower() got = %v, want %v", got, want)
+	}
+
+	if _, err = apdu.NewRapdu(make([]byte, apdu.MaxLenResponseDataExtended+1), 0x9000).StringLower(); err == nil {
+		t.Error("StringLower() expected error")
+	}
+}
+
+// TestRapdu_StringLowerAllocs is not parallel as allocations of other tests would be counted.
+func TestRapdu_StringLowerAllocs(t *testing.T) {
+	r := apdu.NewRapdu([]byte{0x01, 0x02, 0x03, 0x04, 0x05}, 0x9000)
+
+	if allocs := testing.AllocsPerRun(100, func() { _, _ = r.StringLower() }); allocs != 1 {
+		t.Errorf("StringLower() allocs = %v, want 1", allocs)
+	}
+}
+
 func TestRapdu_IsSuccess(t *testing.T) {
 	t.Parallel()
 
@@ -418,56 +715,726 @@ func TestRapdu_IsError(t *testing.T) {
 	}
 }
 
-func benchmarkParseRapdu(b *testing.B, by []byte) {
-	b.Helper()
-
-	b.ReportAllocs()
+func TestRapdu_WarningPredicates(t *testing.T) {
+	t.Parallel()
 
-	for b.Loop() {
-		_, _ = apdu.ParseRapdu(by)
+	tests := []struct {
+		name            string
+		sw1, sw2        byte
+		wantCorrupted   bool
+		wantEOFReached  bool
+		wantDeactivated bool
+	}{
+		{
+			name:          "data corrupted",
+			sw1:           0x62,
+			sw2:           0x81,
+			wantCorrupted: true,
+		},
+		{
+			name:           "end of file reached",
+			sw1:            0x62,
+			sw2:            0x82,
+			wantEOFReached: true,
+		},
+		{
+			name:            "deactivated",
+			sw1:             0x62,
+			sw2:             0x83,
+			wantDeactivated: true,
+		},
+		{
+			name: "other warning",
+			sw1:  0x62,
+			sw2:  0x84,
+		},
+		{
+			name: "same SW2 different SW1",
+			sw1:  0x63,
+			sw2:  0x82,
+		},
+		{
+			name: "success",
+			sw1:  0x90,
+			sw2:  0x00,
+		},
 	}
-}
-
-func BenchmarkParseRapduTrailerOnly(b *testing.B) {
-	benchmarkParseRapdu(b, []byte{0x90, 0x00})
-}
-
-func BenchmarkParseRapduTrailerAndData(b *testing.B) {
-	benchmarkParseRapdu(b, []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x90, 0x00})
-}
-
-func benchmarkParseRapduHexString(b *testing.B, s string) {
-	b.Helper()
 
-	b.ReportAllocs()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
 
-	for b.Loop() {
-		_, _ = apdu.ParseRapduHexString(s)
+			r := apdu.Rapdu{Data: []byte{0x01}, SW1: tt.sw1, SW2: tt.sw2}
+			if got := r.IsDataCorrupted(); got != tt.wantCorrupted {
+				t.Errorf("IsDataCorrupted() = %v, want %v", got, tt.wantCorrupted)
+			}
+			if got := r.IsEOFReached(); got != tt.wantEOFReached {
+				t.Errorf("IsEOFReached() = %v, want %v", got, tt.wantEOFReached)
+			}
+			if got := r.IsDeactivated(); got != tt.wantDeactivated {
+				t.Errorf("IsDeactivated() = %v, want %v", got, tt.wantDeactivated)
+			}
+		})
 	}
 }
 
-func BenchmarkParseRapduHexStringTrailerOnly(b *testing.B) {
-	benchmarkParseRapduHexString(b, "9000")
-}
-
-func BenchmarkParseRapduHexStringTrailerAndData(b *testing.B) {
-	benchmarkParseRapduHexString(b, "01020304059000")
-}
+func TestRapdu_WrongLengthHint(t *testing.T) {
+	t.Parallel()
 
-func benchmarkRapduBytes(b *testing.B, c apdu.Rapdu) {
-	b.Helper()
+	tests := []struct {
+		name   string
+		sw     uint16
+		want   int
+		wantOk bool
+	}{
+		{
+			name:   "hinted length",
+			sw:     0x6710,
+			want:   0x10,
+			wantOk: true,
+		},
+		{
+			name: "wrong length without hint",
+			sw:   0x6700,
+		},
+		{
+			name: "wrong Le",
+			sw:   0x6C10,
+		},
+		{
+			name: "success",
+			sw:   0x9000,
+		},
+	}
 
-	b.ReportAllocs()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
 
-	for b.Loop() {
-		_, _ = c.Bytes()
+			got, gotOk := apdu.NewRapdu(nil, tt.sw).WrongLengthHint()
+			if got != tt.want || gotOk != tt.wantOk {
+				t.Errorf("WrongLengthHint() got = (%d, %v), want (%d, %v)", got, gotOk, tt.want, tt.wantOk)
+			}
+		})
 	}
 }
 
-func BenchmarkRapdu_BytesOTrailerOnly(b *testing.B) {
-	benchmarkRapduBytes(b, apdu.Rapdu{SW1: 0x90, SW2: 0x00})
-}
+func TestRapdu_DataTrimmed(t *testing.T) {
+	t.Parallel()
 
-func BenchmarkRapdu_BytesTrailerAndData(b *testing.B) {
-	benchmarkRapduBytes(b, apdu.Rapdu{Data: []byte{0x01, 0x02, 0x03, 0x04, 0x05}, SW1: 0x90, SW2: 0x00})
+	tests := []struct {
+		name string
+		data []byte
+		pad  byte
+		want []byte
+	}{
+		{
+			name: "trailing zero pad",
+			data: []byte{0x01, 0x00, 0x02, 0x00, 0x00},
+			pad:  0x00,
+			want: []byte{0x01, 0x00, 0x02},
+		},
+		{
+			name: "trailing FF pad",
+			data: []byte{0x01, 0x02, 0xFF, 0xFF},
+			pad:  0xFF,
+			want: []byte{0x01, 0x02},
+		},
+		{
+			name: "other pad byte kept",
+			data: []byte{0x01, 0x02, 0xFF},
+			pad:  0x00,
+			want: []byte{0x01, 0x02, 0xFF},
+		},
+		{
+			name: "only pad",
+			data: []byte{0x00, 0x00},
+			pad:  0x00,
+			want: []byte{},
+		},
+		{
+			name: "no data",
+			pad:  0x00,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := apdu.NewRapdu(tt.data, 0x9000).DataTrimmed(tt.pad); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DataTrimmed() got = %X, want %X", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRapdu_EqualTrimmed(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		r     apdu.Rapdu
+		other apdu.Rapdu
+		want  bool
+	}{
+		{
+			name:  "equal after trimming",
+			r:     apdu.NewRapdu([]byte{0x01, 0x02, 0x00, 0x00}, 0x9000),
+			other: apdu.NewRapdu([]byte{0x01, 0x02}, 0x9000),
+			want:  true,
+		},
+		{
+			name:  "different data",
+			r:     apdu.NewRapdu([]byte{0x01, 0x03, 0x00}, 0x9000),
+			other: apdu.NewRapdu([]byte{0x01, 0x02}, 0x9000),
+		},
+		{
+			name:  "internal pad differs",
+			r:     apdu.NewRapdu([]byte{0x01, 0x00, 0x02}, 0x9000),
+			other: apdu.NewRapdu([]byte{0x01, 0x02}, 0x9000),
+		},
+		{
+			name:  "different status word",
+			r:     apdu.NewRapdu([]byte{0x01, 0x02, 0x00}, 0x6282),
+			other: apdu.NewRapdu([]byte{0x01, 0x02}, 0x9000),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tt.r.EqualTrimmed(tt.other, 0x00); got != tt.want {
+				t.Errorf("EqualTrimmed() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRapdu_FitsIFS(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		data []byte
+		ifs  int
+		want bool
+	}{
+		{name: "status word only", ifs: 2, want: true},
+		{name: "fits exactly", data: make([]byte, 252), ifs: 254, want: true},
+		{name: "exceeds by one byte", data: make([]byte, 253), ifs: 254},
+		{name: "maximum standard response", data: make([]byte, 256), ifs: 254},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := apdu.NewRapdu(tt.data, 0x9000).FitsIFS(tt.ifs); got != tt.want {
+				t.Errorf("FitsIFS() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRapdu_TruncateToNe(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		r          apdu.Rapdu
+		ne         int
+		want       apdu.Rapdu
+		wantWithSW apdu.Rapdu
+	}{
+		{
+			name:       "truncated",
+			r:          apdu.NewRapdu([]byte{0x01, 0x02, 0x03}, 0x9000),
+			ne:         2,
+			want:       apdu.NewRapdu([]byte{0x01, 0x02}, 0x9000),
+			wantWithSW: apdu.NewRapdu([]byte{0x01, 0x02}, 0x6282),
+		},
+		{
+			name:       "exact length",
+			r:          apdu.NewRapdu([]byte{0x01, 0x02}, 0x9000),
+			ne:         2,
+			want:       apdu.NewRapdu([]byte{0x01, 0x02}, 0x9000),
+			wantWithSW: apdu.NewRapdu([]byte{0x01, 0x02}, 0x9000),
+		},
+		{
+			name:       "shorter than Ne",
+			r:          apdu.NewRapdu([]byte{0x01}, 0x6A82),
+			ne:         256,
+			want:       apdu.NewRapdu([]byte{0x01}, 0x6A82),
+			wantWithSW: apdu.NewRapdu([]byte{0x01}, 0x6A82),
+		},
+		{
+			name:       "maximum standard Ne",
+			r:          apdu.NewRapdu(make([]byte, 300), 0x9000),
+			ne:         256,
+			want:       apdu.NewRapdu(make([]byte, 256), 0x9000),
+			wantWithSW: apdu.NewRapdu(make([]byte, 256), 0x6282),
+		},
+		{
+			name:       "no data expected",
+			r:          apdu.NewRapdu([]byte{0x01}, 0x9000),
+			ne:         0,
+			want:       apdu.NewRapdu(nil, 0x9000),
+			wantWithSW: apdu.NewRapdu(nil, 0x6282),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tt.r.TruncateToNe(tt.ne); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TruncateToNe() got = %v, want %v", got, tt.want)
+			}
+			if got := tt.r.TruncateToNeWithSW(tt.ne, 0x6282); !reflect.DeepEqual(got, tt.wantWithSW) {
+				t.Errorf("TruncateToNeWithSW() got = %v, want %v", got, tt.wantWithSW)
+			}
+		})
+	}
+}
+
+func TestRapdu_IsValid(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		sw   uint16
+		want bool
+	}{
+		{name: "success", sw: 0x9000, want: true},
+		{name: "bytes available", sw: 0x6110, want: true},
+		{name: "error", sw: 0x6F00, want: true},
+		{name: "UICC bytes available", sw: 0x9F10, want: true},
+		{name: "all zero", sw: 0x0000},
+		{name: "NULL procedure byte", sw: 0x6000},
+		{name: "SW1 outside 6X and 9X", sw: 0x7000},
+		{name: "all ones", sw: 0xFFFF},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := apdu.NewRapdu(nil, tt.sw).IsValid(); got != tt.want {
+				t.Errorf("IsValid() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRapduParseOptions_RejectInvalidSW(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		b       []byte
+		want    apdu.Rapdu
+		wantErr bool
+	}{
+		{
+			name: "valid status word",
+			b:    []byte{0x01, 0x90, 0x00},
+			want: apdu.Rapdu{Data: []byte{0x01}, SW1: 0x90, SW2: 0x00},
+		},
+		{
+			name:    "error: all zero status word",
+			b:       []byte{0x01, 0x00, 0x00},
+			wantErr: true,
+		},
+		{
+			name:    "error: invalid length",
+			b:       []byte{0x90},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := apdu.RapduParseOptions{RejectInvalidSW: true}.ParseRapdu(tt.b)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseRapdu() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseRapdu() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := apdu.ParseRapdu([]byte{0x00, 0x00}); err != nil {
+		t.Errorf("ParseRapdu() error = %v, want all zero status word accepted by default", err)
+	}
+}
+
+func TestRapdu_UICCBytesAvailable(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		sw     uint16
+		want   int
+		wantOk bool
+	}{
+		{
+			name:   "9F bytes available",
+			sw:     0x9F1A,
+			want:   0x1A,
+			wantOk: true,
+		},
+		{
+			name:   "9F00 means 256",
+			sw:     0x9F00,
+			want:   256,
+			wantOk: true,
+		},
+		{
+			name: "ISO 61xx not UICC",
+			sw:   0x6110,
+		},
+		{
+			name: "success",
+			sw:   0x9000,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, gotOk := apdu.NewRapdu(nil, tt.sw).UICCBytesAvailable()
+			if got != tt.want || gotOk != tt.wantOk {
+				t.Errorf("UICCBytesAvailable() got = (%d, %v), want (%d, %v)", got, gotOk, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestRapdu_HasUsableData(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		r    apdu.Rapdu
+		want bool
+	}{
+		{
+			name: "success with data",
+			r:    apdu.Rapdu{Data: []byte{0x01}, SW1: 0x90, SW2: 0x00},
+			want: true,
+		},
+		{
+			name: "more data available with data",
+			r:    apdu.Rapdu{Data: []byte{0x01}, SW1: 0x61, SW2: 0x10},
+			want: true,
+		},
+		{
+			name: "end of file warning with data",
+			r:    apdu.Rapdu{Data: []byte{0x01}, SW1: 0x62, SW2: 0x82},
+			want: true,
+		},
+		{
+			name: "counter warning with data",
+			r:    apdu.Rapdu{Data: []byte{0x01}, SW1: 0x63, SW2: 0xC2},
+			want: true,
+		},
+		{
+			name: "success without data",
+			r:    apdu.Rapdu{SW1: 0x90, SW2: 0x00},
+			want: false,
+		},
+		{
+			name: "error with data",
+			r:    apdu.Rapdu{Data: []byte{0x01}, SW1: 0x6A, SW2: 0x82},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tt.r.HasUsableData(); got != tt.want {
+				t.Errorf("HasUsableData() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func benchmarkParseRapdu(b *testing.B, by []byte) {
+	b.Helper()
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		_, _ = apdu.ParseRapdu(by)
+	}
+}
+
+func BenchmarkParseRapduTrailerOnly(b *testing.B) {
+	benchmarkParseRapdu(b, []byte{0x90, 0x00})
+}
+
+func BenchmarkParseRapduTrailerAndData(b *testing.B) {
+	benchmarkParseRapdu(b, []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x90, 0x00})
+}
+
+func benchmarkParseRapduHexString(b *testing.B, s string) {
+	b.Helper()
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		_, _ = apdu.ParseRapduHexString(s)
+	}
+}
+
+func BenchmarkParseRapduHexStringTrailerOnly(b *testing.B) {
+	benchmarkParseRapduHexString(b, "9000")
+}
+
+func BenchmarkParseRapduHexStringTrailerAndData(b *testing.B) {
+	benchmarkParseRapduHexString(b, "01020304059000")
+}
+
+func benchmarkRapduBytes(b *testing.B, c apdu.Rapdu) {
+	b.Helper()
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		_, _ = c.Bytes()
+	}
+}
+
+func BenchmarkRapdu_BytesOTrailerOnly(b *testing.B) {
+	benchmarkRapduBytes(b, apdu.Rapdu{SW1: 0x90, SW2: 0x00})
+}
+
+func BenchmarkRapdu_BytesTrailerAndData(b *testing.B) {
+	benchmarkRapduBytes(b, apdu.Rapdu{Data: []byte{0x01, 0x02, 0x03, 0x04, 0x05}, SW1: 0x90, SW2: 0x00})
+}
+
+func TestRapdu_MatchPattern(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		sw      uint16
+		pattern string
+		want    bool
+		wantErr bool
+	}{
+		{
+			name:    "exact match",
+			sw:      0x9000,
+			pattern: "9000",
+			want:    true,
+		},
+		{
+			name:    "exact mismatch",
+			sw:      0x9001,
+			pattern: "9000",
+		},
+		{
+			name:    "byte wildcard",
+			sw:      0x6110,
+			pattern: "61XX",
+			want:    true,
+		},
+		{
+			name:    "lowercase",
+			sw:      0x6A82,
+			pattern: "6a8x",
+			want:    true,
+		},
+		{
+			name:    "nibble wildcard",
+			sw:      0x6A82,
+			pattern: "6A8?",
+			want:    true,
+		},
+		{
+			name:    "nibble wildcard mismatch",
+			sw:      0x6A92,
+			pattern: "6A8?",
+		},
+		{
+			name:    "error: too short",
+			sw:      0x9000,
+			pattern: "900",
+			wantErr: true,
+		},
+		{
+			name:    "error: invalid character",
+			sw:      0x9000,
+			pattern: "90G0",
+			wantErr: true,
+		},
+		{
+			name:    "error: invalid character after mismatch",
+			sw:      0x6A82,
+			pattern: "90Z0",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := apdu.NewRapdu(nil, tt.sw).MatchPattern(tt.pattern)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("MatchPattern() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+			if got != tt.want {
+				t.Errorf("MatchPattern() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRapdu_SplitForT0(t *testing.T) {
+	t.Parallel()
+
+	data := make([]byte, 600)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	tests := []struct {
+		name    string
+		r       apdu.Rapdu
+		chunk   int
+		wantSWs []uint16
+		wantLen []int
+	}{
+		{
+			name:    "status word only",
+			r:       apdu.NewRapdu(nil, 0x6A82),
+			chunk:   256,
+			wantSWs: []uint16{0x6A82},
+			wantLen: []int{0},
+		},
+		{
+			name:    "fits in one chunk",
+			r:       apdu.NewRapdu(data[:256], 0x9000),
+			chunk:   256,
+			wantSWs: []uint16{0x9000},
+			wantLen: []int{256},
+		},
+		{
+			name:    "more than 256 remaining",
+			r:       apdu.NewRapdu(data, 0x6282),
+			chunk:   256,
+			wantSWs: []uint16{0x6100, 0x6158, 0x6282},
+			wantLen: []int{256, 256, 88},
+		},
+		{
+			name:    "small chunks",
+			r:       apdu.NewRapdu(data[:5], 0x9000),
+			chunk:   2,
+			wantSWs: []uint16{0x6103, 0x6101, 0x9000},
+			wantLen: []int{2, 2, 1},
+		},
+		{
+			name:    "chunk capped at 256",
+			r:       apdu.NewRapdu(data[:300], 0x9000),
+			chunk:   1000,
+			wantSWs: []uint16{0x612C, 0x9000},
+			wantLen: []int{256, 44},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := tt.r.SplitForT0(tt.chunk)
+			if len(got) != len(tt.wantSWs) {
+				t.Fatalf("SplitForT0() returned %d responses, want %d", len(got), len(tt.wantSWs))
+			}
+
+			var joined []byte
+			for i, r := range got {
+				if r.SW() != tt.wantSWs[i] {
+					t.Errorf("SplitForT0()[%d] SW = %04X, want %04X", i, r.SW(), tt.wantSWs[i])
+				}
+				if len(r.Data) != tt.wantLen[i] {
+					t.Errorf("SplitForT0()[%d] len(Data) = %d, want %d", i, len(r.Data), tt.wantLen[i])
+				}
+				joined = append(joined, r.Data...)
+			}
+			if !bytes.Equal(joined, tt.r.Data) {
+				t.Errorf("SplitForT0() data does not reassemble to the original")
+			}
+		})
+	}
+}
+
+func TestRapdu_Family(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		sw   uint16
+		want uint16
+	}{
+		{sw: 0x9000, want: 0x9000},
+		{sw: 0x6110, want: 0x6100},
+		{sw: 0x6100, want: 0x6100},
+		{sw: 0x6C20, want: 0x6C00},
+		{sw: 0x63C2, want: 0x63C0},
+		{sw: 0x6300, want: 0x6300},
+		{sw: 0x9F1A, want: 0x9F00},
+		{sw: 0x6A82, want: 0x6A82},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%04X", tt.sw), func(t *testing.T) {
+			t.Parallel()
+
+			if got := apdu.NewRapdu(nil, tt.sw).Family(); got != tt.want {
+				t.Errorf("Family() = %04X, want %04X", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSameCategory(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		a, b uint16
+		want bool
+	}{
+		{name: "both success", a: 0x9000, b: 0x6110, want: true},
+		{name: "both warning", a: 0x6282, b: 0x63C2, want: true},
+		{name: "both error", a: 0x6A82, b: 0x6700, want: true},
+		{name: "security-related issue is error", a: 0x6600, b: 0x6982, want: true},
+		{name: "success and warning", a: 0x9000, b: 0x6282},
+		{name: "warning and error", a: 0x6283, b: 0x6A88},
+		{name: "uncategorized", a: 0x9F10, b: 0x9F10},
+		{name: "uncategorized and success", a: 0x9F10, b: 0x9000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := apdu.SameCategory(apdu.NewRapdu(nil, tt.a), apdu.NewRapdu(nil, tt.b)); got != tt.want {
+				t.Errorf("SameCategory() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
 }
@@ -0,0 +1,153 @@
+package apdu
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Direction is the direction of an APDU relative to the card.
+type Direction int
+
+const (
+	// ToCard is the direction of a command sent to the card.
+	ToCard Direction = iota + 1
+	// FromCard is the direction of a response received from the card.
+	FromCard
+)
+
+// String returns "to_card" or "from_card".
+func (d Direction) String() string {
+	switch d {
+	case ToCard:
+		return "to_card"
+	case FromCard:
+		return "from_card"
+	}
+
+	return fmt.Sprintf("Direction(%d)", int(d))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (d Direction) MarshalText() ([]byte, error) {
+	if d != ToCard && d != FromCard {
+		return nil, fmt.Errorf("%s: invalid direction %d", packageTag, int(d))
+	}
+
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (d *Direction) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "to_card":
+		*d = ToCard
+	case "from_card":
+		*d = FromCard
+	default:
+		return fmt.Errorf("%s: invalid direction %q", packageTag, text)
+	}
+
+	return nil
+}
+
+// Record is an entry of an APDU transcript with an externally supplied timestamp and direction.
+type Record struct {
+	Time     time.Time // Time is the time the APDU was sent or received.
+	Dir      Direction // Dir is the direction of the APDU.
+	Command  *Capdu    // Command is the command APDU, if any.
+	Response *Rapdu    // Response is the response APDU, if any.
+}
+
+type recordJSON struct {
+	Time     time.Time `json:"time,omitzero"`
+	Dir      Direction `json:"dir,omitzero"`
+	Command  string    `json:"command,omitempty"`
+	Label    string    `json:"label,omitempty"`
+	Response string    `json:"response,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler, emitting a compact object with the command and response as hex strings,
+// e.g. {"time":"2024-01-02T03:04:05Z","dir":"to_card","command":"00A4040000"}. The Label of the command is included.
+func (r Record) MarshalJSON() ([]byte, error) {
+	v := recordJSON{Time: r.Time, Dir: r.Dir}
+
+	var err error
+	if r.Command != nil {
+		if v.Command, err = r.Command.String(); err != nil {
+			return nil, err
+		}
+		v.Label = r.Command.Label
+	}
+
+	if r.Response != nil {
+		if v.Response, err = r.Response.String(); err != nil {
+			return nil, err
+		}
+	}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for the format emitted by MarshalJSON.
+func (r *Record) UnmarshalJSON(b []byte) error {
+	var v recordJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	rec := Record{Time: v.Time, Dir: v.Dir}
+
+	if v.Command != "" {
+		c, err := ParseCapduHexString(v.Command)
+		if err != nil {
+			return err
+		}
+		c.Label = v.Label
+		rec.Command = &c
+	}
+
+	if v.Response != "" {
+		resp, err := ParseRapduHexString(v.Response)
+		if err != nil {
+			return err
+		}
+		rec.Response = &resp
+	}
+
+	*r = rec
+
+	return nil
+}
+
+// WriteTranscript writes records to w as line delimited JSON, one Record per line as emitted by Record.MarshalJSON.
+func WriteTranscript(w io.Writer, records []Record) error {
+	enc := json.NewEncoder(w)
+	for i, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return fmt.Errorf("%s: record %d: %w", packageTag, i, err)
+		}
+	}
+
+	return nil
+}
+
+// ReadTranscript reads line delimited JSON records as written by WriteTranscript from r until EOF.
+func ReadTranscript(r io.Reader) ([]Record, error) {
+	var records []Record
+
+	dec := json.NewDecoder(r)
+	for {
+		var rec Record
+		if err := dec.Decode(&rec); err != nil {
+			if errors.Is(err, io.EOF) {
+				return records, nil
+			}
+
+			return nil, fmt.Errorf("%s: record %d: %w", packageTag, len(records), err)
+		}
+		records = append(records, rec)
+	}
+}
@@ -0,0 +1,150 @@
+package apdu_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/nvx/go-apdu"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecord_MarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 600000000, time.UTC)
+
+	tests := []struct {
+		name    string
+		record  apdu.Record
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "command",
+			record: apdu.Record{Time: ts, Dir: apdu.ToCard, Command: &apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, Data: []byte{0xA0, 0x00}, Ne: 256, Label: "Select"}},
+			want:   `{"time":"2024-01-02T03:04:05.6Z","dir":"to_card","command":"00A4040002A00000","label":"Select"}`,
+		},
+		{
+			name:   "response",
+			record: apdu.Record{Time: ts, Dir: apdu.FromCard, Response: &apdu.Rapdu{Data: []byte{0x01}, SW1: 0x90, SW2: 0x00}},
+			want:   `{"time":"2024-01-02T03:04:05.6Z","dir":"from_card","response":"019000"}`,
+		},
+		{
+			name:   "no time",
+			record: apdu.Record{Dir: apdu.FromCard, Response: &apdu.Rapdu{SW1: 0x6A, SW2: 0x82}},
+			want:   `{"dir":"from_card","response":"6A82"}`,
+		},
+		{
+			name:    "error: invalid direction",
+			record:  apdu.Record{Dir: 3, Response: &apdu.Rapdu{SW1: 0x90, SW2: 0x00}},
+			wantErr: true,
+		},
+		{
+			name:    "error: invalid command",
+			record:  apdu.Record{Dir: apdu.ToCard, Command: &apdu.Capdu{Ne: 65537}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := json.Marshal(tt.record)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("MarshalJSON() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if string(got) != tt.want {
+				t.Errorf("MarshalJSON() got = %s, want %s", got, tt.want)
+			}
+
+			var back apdu.Record
+			if err = json.Unmarshal(got, &back); err != nil {
+				t.Fatalf("UnmarshalJSON() error = %v", err)
+			}
+			if !reflect.DeepEqual(back, tt.record) {
+				t.Errorf("UnmarshalJSON() got = %+v, want %+v", back, tt.record)
+			}
+		})
+	}
+}
+
+func TestRecord_UnmarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		s    string
+	}{
+		{
+			name: "invalid direction",
+			s:    `{"dir":"sideways"}`,
+		},
+		{
+			name: "invalid command",
+			s:    `{"dir":"to_card","command":"00"}`,
+		},
+		{
+			name: "invalid response",
+			s:    `{"dir":"from_card","response":"90"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var r apdu.Record
+			if err := json.Unmarshal([]byte(tt.s), &r); err == nil {
+				t.Errorf("UnmarshalJSON() expected error")
+			}
+		})
+	}
+}
+
+func TestTranscript(t *testing.T) {
+	t.Parallel()
+
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	records := []apdu.Record{
+		{Time: ts, Dir: apdu.ToCard, Command: &apdu.Capdu{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x00, Data: []byte{0xA0, 0x00}, Ne: 256, Label: "Select"}},
+		{Time: ts.Add(time.Millisecond), Dir: apdu.FromCard, Response: &apdu.Rapdu{Data: []byte{0x6F, 0x00}, SW1: 0x90, SW2: 0x00}},
+		{Time: ts.Add(2 * time.Millisecond), Dir: apdu.ToCard, Command: &apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Ne: 65536}},
+		{Time: ts.Add(3 * time.Millisecond), Dir: apdu.FromCard, Response: &apdu.Rapdu{SW1: 0x6A, SW2: 0x82}},
+	}
+
+	var buf bytes.Buffer
+	if err := apdu.WriteTranscript(&buf, records); err != nil {
+		t.Fatalf("WriteTranscript() error = %v", err)
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != len(records) {
+		t.Errorf("WriteTranscript() wrote %d lines, want %d", lines, len(records))
+	}
+
+	got, err := apdu.ReadTranscript(&buf)
+	if err != nil {
+		t.Fatalf("ReadTranscript() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, records) {
+		t.Errorf("ReadTranscript() got = %+v, want %+v", got, records)
+	}
+
+	if _, err = apdu.ReadTranscript(strings.NewReader(`{"dir":"to_card","command":"00A40400"}` + "\n" + `{"dir":"to_card","command":"00"}`)); err == nil {
+		t.Errorf("ReadTranscript() expected error for invalid record")
+	}
+
+	if got, err = apdu.ReadTranscript(strings.NewReader("")); err != nil || len(got) != 0 {
+		t.Errorf("ReadTranscript() of empty input got = (%v, %v), want no records", got, err)
+	}
+
+	if err = apdu.WriteTranscript(&buf, []apdu.Record{{Dir: 3}}); err == nil {
+		t.Errorf("WriteTranscript() expected error for invalid record")
+	}
+}
@@ -0,0 +1,74 @@
+package apdu
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maxScanLineLen is the maximum line length accepted by CapduScanner, enough for the largest extended length Capdu
+// with separators between all byte.
+const maxScanLineLen = 1 << 20
+
+// CapduScanner reads Command APDUs from hex encoded lines, e.g. of a log file, one at a time without loading the whole
+// input. Whitespace and colon separators as well as 0x prefixes are ignored, blank lines and comment lines starting
+// with '#' or "//" are skipped.
+type CapduScanner struct {
+	s     *bufio.Scanner
+	line  int
+	capdu Capdu
+	err   error
+}
+
+// NewCapduScanner returns a CapduScanner reading from r.
+func NewCapduScanner(r io.Reader) *CapduScanner {
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, maxScanLineLen)
+
+	return &CapduScanner{s: s}
+}
+
+// Scan advances to the next Capdu, which is then available through Capdu. It returns false when the input is exhausted
+// or an error occurred, Err returns the error if any. A line that can not be parsed stops the scan.
+func (s *CapduScanner) Scan() bool {
+	if s.err != nil {
+		return false
+	}
+
+	for s.s.Scan() {
+		s.line++
+
+		line := strings.TrimSpace(s.s.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") {
+			continue
+		}
+
+		c, err := ParseCapduHexString(normalizeHex(line))
+		if err != nil {
+			s.err = fmt.Errorf("%s: line %d: %w", packageTag, s.line, err)
+
+			return false
+		}
+
+		s.capdu = c
+
+		return true
+	}
+
+	if err := s.s.Err(); err != nil {
+		s.err = fmt.Errorf("%s: line %d: %w", packageTag, s.line+1, err)
+	}
+
+	return false
+}
+
+// Capdu returns the Capdu parsed by the last successful call to Scan.
+func (s *CapduScanner) Capdu() Capdu {
+	return s.capdu
+}
+
+// Err returns the first error encountered by Scan.
+func (s *CapduScanner) Err() error {
+	return s.err
+}
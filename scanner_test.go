@@ -0,0 +1,76 @@
+package apdu_test
+
+import (
+	"github.com/nvx/go-apdu"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCapduScanner(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		input   string
+		want    []apdu.Capdu
+		wantErr bool
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:  "lines with comments and separators",
+			input: "# select\n00A4040C023F00\n\n  // read\n00 b0 00 00 00\r\n0x00:0xC0:0x00:0x00:0x10",
+			want: []apdu.Capdu{
+				{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x0C, Data: []byte{0x3F, 0x00}},
+				{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Ne: 256},
+				{CLA: 0x00, INS: 0xC0, P1: 0x00, P2: 0x00, Ne: 16},
+			},
+		},
+		{
+			name:  "error: invalid line",
+			input: "00B0000000\n00A4040C053F00\n00B0000000",
+			want: []apdu.Capdu{
+				{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Ne: 256},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := apdu.NewCapduScanner(strings.NewReader(tt.input))
+
+			var got []apdu.Capdu
+			for s.Scan() {
+				got = append(got, s.Capdu())
+			}
+			if (s.Err() != nil) != tt.wantErr {
+				t.Errorf("Err() = %v, wantErr %v", s.Err(), tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Scan() got = %v, want %v", got, tt.want)
+			}
+			if s.Scan() {
+				t.Error("Scan() = true after end of input")
+			}
+		})
+	}
+}
+
+func TestCapduScannerLongLine(t *testing.T) {
+	t.Parallel()
+
+	line := "00D6000000FFFF" + strings.Repeat("AB", 65535)
+
+	s := apdu.NewCapduScanner(strings.NewReader(line))
+	if !s.Scan() {
+		t.Fatalf("Scan() = false, err = %v", s.Err())
+	}
+	if got := len(s.Capdu().Data); got != 65535 {
+		t.Errorf("len(Data) = %d, want 65535", got)
+	}
+}
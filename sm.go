@@ -0,0 +1,154 @@
+package apdu
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	// TagSMEncryptedData defines the secure messaging tag of the padding-content indicator followed by a cryptogram.
+	TagSMEncryptedData = 0x87
+	// TagSMLe defines the secure messaging tag of the protected Le.
+	TagSMLe = 0x97
+	// TagSMStatus defines the secure messaging tag of the processing status.
+	TagSMStatus = 0x99
+	// TagSMMAC defines the secure messaging tag of the cryptographic checksum.
+	TagSMMAC = 0x8E
+)
+
+// SMEnvelope parses the secure messaging data objects of ISO 7816-4 from the data field of the Rapdu. It returns the
+// value of the encrypted data object 0x87 (padding-content indicator byte followed by the cryptogram), the value of the
+// MAC data object 0x8E and the complete processing status data object 0x99 including tag and length, as it is
+// covered by the MAC. Absent data objects are returned as nil, other data objects are skipped. No cryptographic
+// operation is performed.
+func (r Rapdu) SMEnvelope() (encryptedData []byte, mac []byte, statusTLV []byte, err error) {
+	b := r.Data
+	for len(b) > 0 {
+		var (
+			tag        uint32
+			value, tlv []byte
+		)
+
+		tag, value, tlv, b, err = parseTLV(b)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		var dst *[]byte
+		switch tag {
+		case TagSMEncryptedData:
+			dst = &encryptedData
+		case TagSMMAC:
+			dst = &mac
+		case TagSMStatus:
+			// the complete data object is input to the MAC calculation
+			dst, value = &statusTLV, tlv
+		default:
+			continue
+		}
+
+		if *dst != nil {
+			return nil, nil, nil, fmt.Errorf("%s: duplicate secure messaging data object %02X", packageTag, tag)
+		}
+		*dst = value
+	}
+
+	return encryptedData, mac, statusTLV, nil
+}
+
+// BuildSMEnvelope returns the data field of a secure messaging protected command consisting of the data objects 0x87
+// with encryptedData (padding-content indicator byte followed by the cryptogram), 0x97 with le encoded like the Le of a
+// standard (up to 256) or extended (up to 65536) command and 0x8E with the mac. Data objects with empty encryptedData,
+// le 0 or empty mac are omitted. No cryptographic operation is performed.
+func BuildSMEnvelope(encryptedData []byte, le int, mac []byte) ([]byte, error) {
+	if le < 0 || le > MaxLenResponseDataExtended {
+		return nil, fmt.Errorf("%s: invalid le %d - must be between 0 and %d", packageTag, le, MaxLenResponseDataExtended)
+	}
+
+	if len(encryptedData) > MaxLenCommandDataExtended || len(mac) > MaxLenCommandDataExtended {
+		return nil, fmt.Errorf("%s: secure messaging data object exceeds maximum length of %d", packageTag, MaxLenCommandDataExtended)
+	}
+
+	var b []byte
+	if len(encryptedData) > 0 {
+		b = appendTLV(b, TagSMEncryptedData, encryptedData)
+	}
+
+	switch {
+	case le == 0:
+	case le <= MaxLenResponseDataStandard:
+		b = appendTLV(b, TagSMLe, []byte{byte(le)})
+	default:
+		b = appendTLV(b, TagSMLe, []byte{byte(le >> 8), byte(le)})
+	}
+
+	if len(mac) > 0 {
+		b = appendTLV(b, TagSMMAC, mac)
+	}
+
+	return b, nil
+}
+
+// appendTLV appends the BER-TLV data object with the single byte tag and value to b, using the short length form
+// up to 127 byte and the long form 0x81 or 0x82 above.
+func appendTLV(b []byte, tag byte, value []byte) []byte {
+	b = append(b, tag)
+
+	switch l := len(value); {
+	case l < 0x80:
+		b = append(b, byte(l))
+	case l <= 0xFF:
+		b = append(b, 0x81, byte(l))
+	default:
+		b = append(b, 0x82, byte(l>>8), byte(l))
+	}
+
+	return append(b, value...)
+}
+
+// parseTLV parses the first BER-TLV data object of b and returns its tag, value, the complete encoding and the bytes
+// following it. Tags of up to 4 byte and definite lengths of up to 3 byte following 0x81 to 0x83 are supported.
+func parseTLV(b []byte) (tag uint32, value, tlv, rest []byte, err error) {
+	i := 0
+
+	tag = uint32(b[i])
+	i++
+	if tag&0x1F == 0x1F {
+		for {
+			if i >= len(b) || i >= 4 {
+				return 0, nil, nil, nil, fmt.Errorf("%s: invalid TLV - truncated or too long tag", packageTag)
+			}
+			tag = tag<<8 | uint32(b[i])
+			i++
+			if b[i-1]&0x80 == 0 {
+				break
+			}
+		}
+	}
+
+	if i >= len(b) {
+		return 0, nil, nil, nil, fmt.Errorf("%s: invalid TLV - missing length of tag %X", packageTag, tag)
+	}
+
+	length := int(b[i])
+	i++
+	if length > 0x80 {
+		n := length & 0x7F
+		if n > 3 || i+n > len(b) {
+			return 0, nil, nil, nil, fmt.Errorf("%s: invalid TLV - unsupported or truncated length of tag %X", packageTag, tag)
+		}
+
+		var lb [4]byte
+		copy(lb[4-n:], b[i:i+n])
+		length = int(binary.BigEndian.Uint32(lb[:]))
+		i += n
+	} else if length == 0x80 {
+		return 0, nil, nil, nil, fmt.Errorf("%s: invalid TLV - indefinite length of tag %X", packageTag, tag)
+	}
+
+	if length > len(b)-i {
+		return 0, nil, nil, nil, fmt.Errorf("%s: invalid TLV - length %d of tag %X exceeds remaining %d byte", packageTag, length, tag, len(b)-i)
+	}
+
+	return tag, b[i : i+length], b[:i+length], b[i+length:], nil
+}
@@ -0,0 +1,182 @@
+package apdu_test
+
+import (
+	"encoding/hex"
+	"github.com/nvx/go-apdu"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRapdu_SMEnvelope(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name              string
+		data              string
+		wantEncryptedData []byte
+		wantMAC           []byte
+		wantStatusTLV     []byte
+		wantErr           bool
+	}{
+		{
+			name:          "status and MAC",
+			data:          "990290008E0801020304050607 08",
+			wantMAC:       []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+			wantStatusTLV: []byte{0x99, 0x02, 0x90, 0x00},
+		},
+		{
+			name:              "encrypted data, status and MAC",
+			data:              "870901AABBCCDDEEFF0011 99029000 8E080102030405060708",
+			wantEncryptedData: []byte{0x01, 0xAA, 0xBB, 0xCC, 0xDD, 0xEE, 0xFF, 0x00, 0x11},
+			wantMAC:           []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+			wantStatusTLV:     []byte{0x99, 0x02, 0x90, 0x00},
+		},
+		{
+			name:          "long form length and unknown data object",
+			data:          "5F2001AA 99029000 8E8104CAFEBABE",
+			wantMAC:       []byte{0xCA, 0xFE, 0xBA, 0xBE},
+			wantStatusTLV: []byte{0x99, 0x02, 0x90, 0x00},
+		},
+		{
+			name: "empty",
+		},
+		{
+			name:    "error: truncated value",
+			data:    "8E0801020304",
+			wantErr: true,
+		},
+		{
+			name:    "error: missing length",
+			data:    "99029000 8E",
+			wantErr: true,
+		},
+		{
+			name:    "error: indefinite length",
+			data:    "87800100",
+			wantErr: true,
+		},
+		{
+			name:    "error: duplicate MAC",
+			data:    "8E0101 8E0102",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			data, err := hex.DecodeString(strings.ReplaceAll(tt.data, " ", ""))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			encryptedData, mac, statusTLV, err := apdu.NewRapdu(data, 0x9000).SMEnvelope()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SMEnvelope() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+			if !reflect.DeepEqual(encryptedData, tt.wantEncryptedData) {
+				t.Errorf("SMEnvelope() encryptedData = %X, want %X", encryptedData, tt.wantEncryptedData)
+			}
+			if !reflect.DeepEqual(mac, tt.wantMAC) {
+				t.Errorf("SMEnvelope() mac = %X, want %X", mac, tt.wantMAC)
+			}
+			if !reflect.DeepEqual(statusTLV, tt.wantStatusTLV) {
+				t.Errorf("SMEnvelope() statusTLV = %X, want %X", statusTLV, tt.wantStatusTLV)
+			}
+		})
+	}
+}
+
+func TestBuildSMEnvelope(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		encryptedData []byte
+		le            int
+		mac           []byte
+		want          string
+		wantErr       bool
+	}{
+		{
+			name: "MAC only",
+			mac:  []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+			want: "8E080102030405060708",
+		},
+		{
+			name:          "encrypted data, standard Le and MAC",
+			encryptedData: []byte{0x01, 0xAA, 0xBB},
+			le:            256,
+			mac:           []byte{0x01, 0x02, 0x03, 0x04},
+			want:          "870301AABB" + "970100" + "8E0401020304",
+		},
+		{
+			name: "extended Le",
+			le:   65536,
+			want: "97020000",
+		},
+		{
+			name: "extended Le below maximum",
+			le:   257,
+			want: "97020101",
+		},
+		{
+			name:          "long form length",
+			encryptedData: make([]byte, 200),
+			want:          "8781C8" + strings.Repeat("00", 200),
+		},
+		{
+			name: "nothing",
+		},
+		{
+			name:    "error: le too big",
+			le:      65537,
+			wantErr: true,
+		},
+		{
+			name:    "error: negative le",
+			le:      -1,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := apdu.BuildSMEnvelope(tt.encryptedData, tt.le, tt.mac)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("BuildSMEnvelope() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+			if gotHex := strings.ToUpper(hex.EncodeToString(got)); gotHex != tt.want {
+				t.Errorf("BuildSMEnvelope() = %s, want %s", gotHex, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildSMEnvelopeRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	encryptedData := append([]byte{0x01}, make([]byte, 300)...)
+	mac := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}
+
+	b, err := apdu.BuildSMEnvelope(encryptedData, 0, mac)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gotEncryptedData, gotMAC, _, err := apdu.NewRapdu(b, 0x9000).SMEnvelope()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gotEncryptedData, encryptedData) || !reflect.DeepEqual(gotMAC, mac) {
+		t.Errorf("SMEnvelope() = %X, %X, want %X, %X", gotEncryptedData, gotMAC, encryptedData, mac)
+	}
+}
@@ -0,0 +1,73 @@
+package apdu
+
+import "fmt"
+
+// TagRecordTemplate defines the tag of the READ RECORD response message template framing a record.
+const TagRecordTemplate = 0x70
+
+// DataTLVStrings parses the data field of the Capdu as a list of BER-TLV data objects and returns one string per data
+// object for display, e.g. "5A: 1234567890". The value of a constructed data object is parsed one level deep, its
+// children follow the constructed data object indented by two spaces, e.g. "6F:" followed by "  84: A0000000041010".
+// Deeper nested data objects are shown as hex. An error is returned if the data is not a valid TLV list.
+func (c Capdu) DataTLVStrings() ([]string, error) {
+	return appendTLVStrings(nil, c.Data, "", true)
+}
+
+func appendTLVStrings(dst []string, b []byte, indent string, recurse bool) ([]string, error) {
+	for len(b) > 0 {
+		tag, value, _, rest, err := parseTLV(b)
+		if err != nil {
+			return nil, err
+		}
+		b = rest
+
+		width := 2
+		for t := tag >> 8; t != 0; t >>= 8 {
+			width += 2
+		}
+
+		// bit 6 of the first tag byte indicates a constructed data object
+		if !recurse || tag>>(4*(width-2))&0x20 == 0 {
+			dst = append(dst, fmt.Sprintf("%s%0*X: %X", indent, width, tag, value))
+			continue
+		}
+
+		dst = append(dst, fmt.Sprintf("%s%0*X:", indent, width, tag))
+		if dst, err = appendTLVStrings(dst, value, indent+"  ", false); err != nil {
+			return nil, err
+		}
+	}
+
+	return dst, nil
+}
+
+// SplitRecords returns the values of the record templates 0x70 the data of the Rapdu consists of, e.g. for multiple
+// records returned by a single READ RECORD(S) command. If the data does not start with the record template tag it is
+// returned as single element, empty data returns nil. An error is returned if the data starts with the record template
+// tag but is not a sequence of record templates. The returned values alias the Data of the Rapdu.
+func (r Rapdu) SplitRecords() ([][]byte, error) {
+	if len(r.Data) == 0 {
+		return nil, nil
+	}
+
+	if r.Data[0] != TagRecordTemplate {
+		return [][]byte{r.Data}, nil
+	}
+
+	var records [][]byte
+	for b := r.Data; len(b) > 0; {
+		tag, value, _, rest, err := parseTLV(b)
+		if err != nil {
+			return nil, err
+		}
+
+		if tag != TagRecordTemplate {
+			return nil, fmt.Errorf("%s: unexpected tag %X following record template", packageTag, tag)
+		}
+
+		records = append(records, value)
+		b = rest
+	}
+
+	return records, nil
+}
@@ -0,0 +1,136 @@
+package apdu_test
+
+import (
+	"encoding/hex"
+	"github.com/nvx/go-apdu"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCapdu_DataTLVStrings(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		data    string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "primitive data objects",
+			data: "5A051234567890 9F02060000000001005F2A020978",
+			want: []string{"5A: 1234567890", "9F02: 000000000100", "5F2A: 0978"},
+		},
+		{
+			name: "constructed data object",
+			data: "6F0E8407A0000000041010A5038801015A0112",
+			want: []string{"6F:", "  84: A0000000041010", "  A5: 880101", "5A: 12"},
+		},
+		{
+			name: "tag with leading zero nibble",
+			data: "0A0101",
+			want: []string{"0A: 01"},
+		},
+		{
+			name: "empty primitive value",
+			data: "5A00",
+			want: []string{"5A: "},
+		},
+		{
+			name: "empty",
+		},
+		{
+			name:    "error: truncated value",
+			data:    "5A051234",
+			wantErr: true,
+		},
+		{
+			name:    "error: invalid constructed value",
+			data:    "6F0284FF",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			data, err := hex.DecodeString(strings.ReplaceAll(tt.data, " ", ""))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := apdu.Capdu{CLA: 0x80, INS: 0xE2, Data: data}.DataTLVStrings()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("DataTLVStrings() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DataTLVStrings() got = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRapdu_SplitRecords(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		data    string
+		want    [][]byte
+		wantErr bool
+	}{
+		{
+			name: "single record",
+			data: "7003 5A0112",
+			want: [][]byte{{0x5A, 0x01, 0x12}},
+		},
+		{
+			name: "multiple records",
+			data: "7003 5A0112 7004 5F200100 7000",
+			want: [][]byte{{0x5A, 0x01, 0x12}, {0x5F, 0x20, 0x01, 0x00}, {}},
+		},
+		{
+			name: "no record template",
+			data: "5A0112",
+			want: [][]byte{{0x5A, 0x01, 0x12}},
+		},
+		{
+			name: "empty",
+		},
+		{
+			name:    "error: truncated record",
+			data:    "7005 5A0112",
+			wantErr: true,
+		},
+		{
+			name:    "error: other data object following record",
+			data:    "7003 5A0112 5A0112",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			data, err := hex.DecodeString(strings.ReplaceAll(tt.data, " ", ""))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := apdu.NewRapdu(data, 0x9000).SplitRecords()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SplitRecords() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitRecords() got = %X, want %X", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package apdu
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Transmitter sends a Capdu to a card and returns its response, e.g. a wrapper around a PC/SC card handle.
+type Transmitter interface {
+	Transmit(ctx context.Context, c Capdu) (Rapdu, error)
+}
+
+// ReadAllRecords reads the records 1, 2, ... of the EF with the short EF identifier sfi (1 to 30) using READ RECORD
+// until the card responds with '0x6A83' (record not found) and returns the responses in order. Each record is read with
+// T0Exchange, so a '0x61xx' response is completed with GET RESPONSE and a '0x6Cxx' response re-issues the command.
+// Responses indicating a warning are kept as they may carry usable data, any other error status word aborts the
+// iteration.
+func ReadAllRecords(ctx context.Context, t Transmitter, sfi byte) ([]Rapdu, error) {
+	var records []Rapdu
+
+	for record := 1; record <= 254; record++ {
+		if err := ctx.Err(); err != nil {
+			return records, err
+		}
+
+		c, err := ReadRecordSFI(sfi, byte(record), MaxLenResponseDataStandard)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := T0Exchange(ctx, t, c)
+		if err != nil {
+			return records, fmt.Errorf("%s: reading record %d of SFI %d: %w", packageTag, record, sfi, err)
+		}
+
+		r := e.Response
+		if r.SW() == 0x6A83 {
+			break
+		}
+
+		if !r.IsSuccess() && !r.IsWarning() {
+			return records, fmt.Errorf("%s: reading record %d of SFI %d: status word %04X", packageTag, record, sfi, r.SW())
+		}
+
+		records = append(records, r)
+	}
+
+	return records, nil
+}
+
+// maxGetResponses limits the number of GET RESPONSE commands T0Exchange issues for a single command, enough to fetch
+// MaxLenResponseDataExtended byte in chunks of MaxLenResponseDataStandard.
+const maxGetResponses = MaxLenResponseDataExtended / MaxLenResponseDataStandard
+
+// T0Exchange drives the command c through the T=0 response handling using t and returns the reassembled exchange.
+// If the card answers '0x6Cxx' (wrong Le) the command is re-issued once with Ne set to xx, the Command of the returned
+// record is the command as re-issued. As long as the card answers '0x61xx' the remaining data is fetched with GET
+// RESPONSE on the same logical channel and appended to the response. An error is returned if ctx is done, a GET
+// RESPONSE is answered with '0x61xx' without data or more than 256 GET RESPONSE commands would be needed.
+func T0Exchange(ctx context.Context, t Transmitter, c Capdu) (ExchangeRecord, error) {
+	r, err := t.Transmit(ctx, c)
+	if err != nil {
+		return ExchangeRecord{}, fmt.Errorf("%s: transmitting command: %w", packageTag, err)
+	}
+
+	if r.SW1 == 0x6C {
+		c.Ne = t0Ne(r.SW2)
+
+		r, err = t.Transmit(ctx, c)
+		if err != nil {
+			return ExchangeRecord{}, fmt.Errorf("%s: re-issuing command with ne %d: %w", packageTag, c.Ne, err)
+		}
+	}
+
+	responses := []Rapdu{r}
+	total := len(r.Data)
+
+	for r.SW1 == 0x61 {
+		if err = ctx.Err(); err != nil {
+			return ExchangeRecord{}, err
+		}
+
+		if len(responses) > maxGetResponses {
+			return ExchangeRecord{}, fmt.Errorf("%s: response requires more than %d GET RESPONSE commands", packageTag, maxGetResponses)
+		}
+
+		if total > MaxLenResponseDataExtended {
+			return ExchangeRecord{}, fmt.Errorf("%s: response data exceeds maximum length of %d", packageTag, MaxLenResponseDataExtended)
+		}
+
+		r, err = t.Transmit(ctx, c.GetResponse(t0Ne(r.SW2)))
+		if err != nil {
+			return ExchangeRecord{}, fmt.Errorf("%s: transmitting GET RESPONSE: %w", packageTag, err)
+		}
+
+		// a card answering GET RESPONSE with further bytes available must return some of them to make progress
+		if r.SW1 == 0x61 && len(r.Data) == 0 {
+			return ExchangeRecord{}, fmt.Errorf("%s: GET RESPONSE answered with status word %04X without data", packageTag, r.SW())
+		}
+
+		responses = append(responses, r)
+		total += len(r.Data)
+	}
+
+	return Exchange(c, responses)
+}
+
+// t0Ne returns the Ne indicated by the SW2 of a '0x61xx' or '0x6Cxx' status word, where 0x00 means 256.
+func t0Ne(sw2 byte) int {
+	if sw2 == 0x00 {
+		return MaxLenResponseDataStandard
+	}
+
+	return int(sw2)
+}
+
+// MaxResponseBuffer returns the expected total number of response byte received for c over T=0 if the card returns
+// the response data in full size chunks, e.g. to pre-allocate a buffer for the raw responses of T0Exchange. It is Ne
+// plus the two byte status word of each response carrying MaxLenResponseDataStandard byte, so a standard length
+// command needs Ne + 2 and a Ne of 65536 needs 65536 + 2 * 256 byte. It is not an upper bound, a card answering in
+// smaller '0x61xx' chunks needs two more byte for each additional response. Ne is clamped to the range 0 to
+// MaxLenResponseDataExtended.
+func MaxResponseBuffer(c Capdu) int {
+	ne := min(max(c.Ne, 0), MaxLenResponseDataExtended)
+	responses := max(1, (ne+MaxLenResponseDataStandard-1)/MaxLenResponseDataStandard)
+
+	return ne + responses*LenResponseTrailer
+}
+
+type rateLimited struct {
+	t           Transmitter
+	minInterval time.Duration
+	// sem serializes transmissions while allowing to give up waiting on context cancellation
+	sem  chan struct{}
+	last time.Time
+}
+
+// RateLimited returns a Transmitter that forwards to t while enforcing a gap of at least minInterval between the end of
+// a transmission and the start of the next one. Concurrent callers are serialized. Waiting for a turn or for the gap
+// to pass is aborted with the error of ctx if it is done.
+func RateLimited(t Transmitter, minInterval time.Duration) Transmitter {
+	return &rateLimited{t: t, minInterval: minInterval, sem: make(chan struct{}, 1)}
+}
+
+func (r *rateLimited) Transmit(ctx context.Context, c Capdu) (Rapdu, error) {
+	select {
+	case r.sem <- struct{}{}:
+	case <-ctx.Done():
+		return Rapdu{}, ctx.Err()
+	}
+	defer func() { <-r.sem }()
+
+	if wait := r.minInterval - time.Since(r.last); !r.last.IsZero() && wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return Rapdu{}, ctx.Err()
+		}
+	}
+
+	defer func() { r.last = time.Now() }()
+
+	return r.t.Transmit(ctx, c)
+}
+
+type logged struct {
+	t      Transmitter
+	logger *slog.Logger
+}
+
+// Logged returns a Transmitter that forwards to t and logs every command before and its response after the
+// transmission at debug level using the LogValue of Capdu and Rapdu, including the duration. Failed transmissions are
+// logged at error level. If logger is nil slog.Default is used. Command and response data are logged as is, including
+// sensitive data such as PINs or keys.
+func Logged(t Transmitter, logger *slog.Logger) Transmitter {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &logged{t: t, logger: logger}
+}
+
+func (l *logged) Transmit(ctx context.Context, c Capdu) (Rapdu, error) {
+	l.logger.LogAttrs(ctx, slog.LevelDebug, "transmitting command", slog.Any("command", c))
+
+	start := time.Now()
+	r, err := l.t.Transmit(ctx, c)
+	duration := time.Since(start)
+
+	if err != nil {
+		l.logger.LogAttrs(ctx, slog.LevelError, "transmission failed", slog.Any("command", c), slog.Duration("duration", duration), slog.Any("error", err))
+
+		return r, err
+	}
+
+	l.logger.LogAttrs(ctx, slog.LevelDebug, "received response", slog.Any("response", r), slog.Duration("duration", duration))
+
+	return r, nil
+}
@@ -0,0 +1,458 @@
+package apdu_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/nvx/go-apdu"
+	"log/slog"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordCard answers READ RECORD commands with the configured responses per record number and '0x6A83' otherwise.
+type recordCard struct {
+	records map[byte]apdu.Rapdu
+	sent    []apdu.Capdu
+	err     error
+}
+
+func (c *recordCard) Transmit(_ context.Context, capdu apdu.Capdu) (apdu.Rapdu, error) {
+	c.sent = append(c.sent, capdu)
+	if c.err != nil {
+		return apdu.Rapdu{}, c.err
+	}
+
+	if r, ok := c.records[capdu.P1]; ok {
+		return r, nil
+	}
+
+	return apdu.NewRapdu(nil, 0x6A83), nil
+}
+
+func TestReadAllRecords(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		records map[byte]apdu.Rapdu
+		err     error
+		want    []apdu.Rapdu
+		wantErr bool
+	}{
+		{
+			name: "no records",
+		},
+		{
+			name: "records with warning",
+			records: map[byte]apdu.Rapdu{
+				1: apdu.NewRapdu([]byte{0x70, 0x00}, 0x9000),
+				2: apdu.NewRapdu([]byte{0x70, 0x01}, 0x6282),
+			},
+			want: []apdu.Rapdu{
+				apdu.NewRapdu([]byte{0x70, 0x00}, 0x9000),
+				apdu.NewRapdu([]byte{0x70, 0x01}, 0x6282),
+			},
+		},
+		{
+			name: "error: status word",
+			records: map[byte]apdu.Rapdu{
+				1: apdu.NewRapdu([]byte{0x70, 0x00}, 0x9000),
+				2: apdu.NewRapdu(nil, 0x6982),
+			},
+			want: []apdu.Rapdu{
+				apdu.NewRapdu([]byte{0x70, 0x00}, 0x9000),
+			},
+			wantErr: true,
+		},
+		{
+			name:    "error: transmit",
+			err:     errors.New("card removed"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			card := &recordCard{records: tt.records, err: tt.err}
+			got, err := apdu.ReadAllRecords(context.Background(), card, 2)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ReadAllRecords() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadAllRecords() got = %v, want %v", got, tt.want)
+			}
+			for i, c := range card.sent {
+				if c.INS != apdu.InsReadRecord || c.P1 != byte(i+1) || c.P2 != 0x14 {
+					t.Errorf("ReadAllRecords() command %d = %v", i, c)
+				}
+			}
+		})
+	}
+}
+
+func TestReadAllRecordsGetResponse(t *testing.T) {
+	t.Parallel()
+
+	card := &scriptedCard{responses: []apdu.Rapdu{
+		apdu.NewRapdu([]byte{0x70, 0x03}, 0x6102),
+		apdu.NewRapdu([]byte{0x01, 0x02}, 0x9000),
+		apdu.NewRapdu(nil, 0x6A83),
+	}}
+
+	got, err := apdu.ReadAllRecords(context.Background(), card, 2)
+	if err != nil {
+		t.Fatalf("ReadAllRecords() error = %v", err)
+	}
+	if want := []apdu.Rapdu{apdu.NewRapdu([]byte{0x70, 0x03, 0x01, 0x02}, 0x9000)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadAllRecords() got = %v, want %v", got, want)
+	}
+
+	wantSent := []apdu.Capdu{
+		{CLA: 0x00, INS: apdu.InsReadRecord, P1: 0x01, P2: 0x14, Ne: 256},
+		{CLA: 0x00, INS: apdu.InsGetResponse, P1: 0x00, P2: 0x00, Ne: 2},
+		{CLA: 0x00, INS: apdu.InsReadRecord, P1: 0x02, P2: 0x14, Ne: 256},
+	}
+	if !reflect.DeepEqual(card.sent, wantSent) {
+		t.Errorf("ReadAllRecords() sent = %v, want %v", card.sent, wantSent)
+	}
+}
+
+func TestReadAllRecordsInvalidSFI(t *testing.T) {
+	t.Parallel()
+
+	if _, err := apdu.ReadAllRecords(context.Background(), &recordCard{}, 31); err == nil {
+		t.Error("ReadAllRecords() expected error")
+	}
+}
+
+func TestReadAllRecordsCanceled(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	card := &recordCard{}
+	if _, err := apdu.ReadAllRecords(ctx, card, 1); !errors.Is(err, context.Canceled) {
+		t.Errorf("ReadAllRecords() error = %v, want %v", err, context.Canceled)
+	}
+	if len(card.sent) != 0 {
+		t.Errorf("ReadAllRecords() sent %d commands, want 0", len(card.sent))
+	}
+}
+
+// scriptedCard returns the scripted responses in order and records the commands sent.
+type scriptedCard struct {
+	responses []apdu.Rapdu
+	sent      []apdu.Capdu
+}
+
+func (c *scriptedCard) Transmit(_ context.Context, capdu apdu.Capdu) (apdu.Rapdu, error) {
+	c.sent = append(c.sent, capdu)
+	if len(c.sent) > len(c.responses) {
+		return apdu.Rapdu{}, errors.New("no more scripted responses")
+	}
+
+	return c.responses[len(c.sent)-1], nil
+}
+
+func TestT0Exchange(t *testing.T) {
+	t.Parallel()
+
+	command := apdu.Capdu{CLA: 0x01, INS: 0xB0, P1: 0x00, P2: 0x00, Ne: 256}
+
+	tests := []struct {
+		name      string
+		responses []apdu.Rapdu
+		want      apdu.ExchangeRecord
+		wantSent  []apdu.Capdu
+		wantErr   bool
+	}{
+		{
+			name:      "direct response",
+			responses: []apdu.Rapdu{apdu.NewRapdu([]byte{0x01}, 0x9000)},
+			want:      apdu.ExchangeRecord{Command: command, Response: apdu.NewRapdu([]byte{0x01}, 0x9000), Frames: 1},
+			wantSent:  []apdu.Capdu{command},
+		},
+		{
+			name: "wrong Le re-issue",
+			responses: []apdu.Rapdu{
+				apdu.NewRapdu(nil, 0x6C02),
+				apdu.NewRapdu([]byte{0x01, 0x02}, 0x9000),
+			},
+			want: apdu.ExchangeRecord{
+				Command:  apdu.Capdu{CLA: 0x01, INS: 0xB0, P1: 0x00, P2: 0x00, Ne: 2},
+				Response: apdu.NewRapdu([]byte{0x01, 0x02}, 0x9000),
+				Frames:   1,
+			},
+			wantSent: []apdu.Capdu{command, {CLA: 0x01, INS: 0xB0, P1: 0x00, P2: 0x00, Ne: 2}},
+		},
+		{
+			name: "GET RESPONSE loop",
+			responses: []apdu.Rapdu{
+				apdu.NewRapdu(nil, 0x6100),
+				apdu.NewRapdu([]byte{0x01, 0x02}, 0x6101),
+				apdu.NewRapdu([]byte{0x03}, 0x9000),
+			},
+			want: apdu.ExchangeRecord{Command: command, Response: apdu.NewRapdu([]byte{0x01, 0x02, 0x03}, 0x9000), Frames: 3},
+			wantSent: []apdu.Capdu{
+				command,
+				{CLA: 0x01, INS: 0xC0, P1: 0x00, P2: 0x00, Ne: 256},
+				{CLA: 0x01, INS: 0xC0, P1: 0x00, P2: 0x00, Ne: 1},
+			},
+		},
+		{
+			name: "wrong Le followed by GET RESPONSE",
+			responses: []apdu.Rapdu{
+				apdu.NewRapdu(nil, 0x6C00),
+				apdu.NewRapdu([]byte{0x01}, 0x6101),
+				apdu.NewRapdu([]byte{0x02}, 0x9000),
+			},
+			want: apdu.ExchangeRecord{Command: command, Response: apdu.NewRapdu([]byte{0x01, 0x02}, 0x9000), Frames: 2},
+			wantSent: []apdu.Capdu{
+				command,
+				command,
+				{CLA: 0x01, INS: 0xC0, P1: 0x00, P2: 0x00, Ne: 1},
+			},
+		},
+		{
+			name: "error: transmit during GET RESPONSE",
+			responses: []apdu.Rapdu{
+				apdu.NewRapdu(nil, 0x6100),
+			},
+			wantSent: []apdu.Capdu{
+				command,
+				{CLA: 0x01, INS: 0xC0, P1: 0x00, P2: 0x00, Ne: 256},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			card := &scriptedCard{responses: tt.responses}
+			got, err := apdu.T0Exchange(context.Background(), card, command)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("T0Exchange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("T0Exchange() got = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(card.sent, tt.wantSent) {
+				t.Errorf("T0Exchange() sent = %v, want %v", card.sent, tt.wantSent)
+			}
+		})
+	}
+}
+
+// repeatCard answers every command with the same response and counts the transmissions.
+type repeatCard struct {
+	response apdu.Rapdu
+	count    int
+}
+
+func (c *repeatCard) Transmit(context.Context, apdu.Capdu) (apdu.Rapdu, error) {
+	c.count++
+
+	return c.response, nil
+}
+
+func TestT0ExchangeRepeatedContinuation(t *testing.T) {
+	t.Parallel()
+
+	command := apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Ne: 256}
+
+	tests := []struct {
+		name      string
+		response  apdu.Rapdu
+		wantCount int
+	}{
+		{
+			name:      "bytes available without data",
+			response:  apdu.NewRapdu(nil, 0x6110),
+			wantCount: 2,
+		},
+		{
+			name:      "bytes available with data forever",
+			response:  apdu.NewRapdu([]byte{0x01}, 0x6101),
+			wantCount: 257,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			card := &repeatCard{response: tt.response}
+			if _, err := apdu.T0Exchange(context.Background(), card, command); err == nil {
+				t.Error("T0Exchange() expected error")
+			}
+			if card.count != tt.wantCount {
+				t.Errorf("T0Exchange() sent %d commands, want %d", card.count, tt.wantCount)
+			}
+		})
+	}
+}
+
+// cancelingCard answers every command with '0x6110' without data and cancels the context on the first transmission.
+type cancelingCard struct {
+	cancel context.CancelFunc
+	count  int
+}
+
+func (c *cancelingCard) Transmit(context.Context, apdu.Capdu) (apdu.Rapdu, error) {
+	c.count++
+	c.cancel()
+
+	return apdu.NewRapdu(nil, 0x6110), nil
+}
+
+func TestT0ExchangeCanceled(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	card := &cancelingCard{cancel: cancel}
+	if _, err := apdu.T0Exchange(ctx, card, apdu.Capdu{CLA: 0x00, INS: 0xB0, Ne: 256}); !errors.Is(err, context.Canceled) {
+		t.Errorf("T0Exchange() error = %v, want %v", err, context.Canceled)
+	}
+	if card.count != 1 {
+		t.Errorf("T0Exchange() sent %d commands, want 1", card.count)
+	}
+}
+
+// echoCard answers every command with '0x9000' and counts the transmissions.
+type echoCard struct {
+	mu    sync.Mutex
+	count int
+}
+
+func (c *echoCard) Transmit(context.Context, apdu.Capdu) (apdu.Rapdu, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.count++
+
+	return apdu.NewRapdu(nil, 0x9000), nil
+}
+
+func TestRateLimited(t *testing.T) {
+	t.Parallel()
+
+	const interval = 20 * time.Millisecond
+
+	card := &echoCard{}
+	rl := apdu.RateLimited(card, interval)
+
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := rl.Transmit(context.Background(), apdu.Capdu{INS: 0xB0}); err != nil {
+				t.Errorf("Transmit() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if elapsed := time.Since(start); elapsed < 2*interval {
+		t.Errorf("3 transmissions took %v, want at least %v", elapsed, 2*interval)
+	}
+	if card.count != 3 {
+		t.Errorf("card received %d commands, want 3", card.count)
+	}
+}
+
+func TestRateLimitedCanceled(t *testing.T) {
+	t.Parallel()
+
+	card := &echoCard{}
+	rl := apdu.RateLimited(card, time.Hour)
+
+	if _, err := rl.Transmit(context.Background(), apdu.Capdu{INS: 0xB0}); err != nil {
+		t.Fatalf("Transmit() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := rl.Transmit(ctx, apdu.Capdu{INS: 0xB0}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Transmit() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if card.count != 1 {
+		t.Errorf("card received %d commands, want 1", card.count)
+	}
+}
+
+func TestLogged(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	card := &scriptedCard{responses: []apdu.Rapdu{apdu.NewRapdu([]byte{0x01, 0x02}, 0x9000)}}
+	l := apdu.Logged(card, logger)
+
+	r, err := l.Transmit(context.Background(), apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Ne: 256})
+	if err != nil {
+		t.Fatalf("Transmit() error = %v", err)
+	}
+	if r.SW() != 0x9000 {
+		t.Errorf("Transmit() SW = %04X, want 9000", r.SW())
+	}
+
+	_, err = l.Transmit(context.Background(), apdu.Capdu{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x01, Ne: 256})
+	if err == nil {
+		t.Fatal("Transmit() expected error")
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		`msg="transmitting command" command.info="00 B0 00 00 (256)"`,
+		`msg="received response" response.status=9000 response.data=0102 duration=`,
+		`level=ERROR msg="transmission failed" command.info="00 B0 00 01 (256)"`,
+		`error="no more scripted responses"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log output %q does not contain %q", out, want)
+		}
+	}
+}
+
+func TestMaxResponseBuffer(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		ne   int
+		want int
+	}{
+		{name: "no response data", ne: 0, want: 2},
+		{name: "standard length", ne: 16, want: 18},
+		{name: "maximum standard length", ne: 256, want: 258},
+		{name: "two responses", ne: 257, want: 261},
+		{name: "maximum extended length", ne: 65536, want: 65536 + 2*256},
+		{name: "negative Ne", ne: -1, want: 2},
+		{name: "Ne exceeding extended length", ne: 65537, want: 65536 + 2*256},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := apdu.MaxResponseBuffer(apdu.Capdu{CLA: 0x00, INS: 0xB0, Ne: tt.ne}); got != tt.want {
+				t.Errorf("MaxResponseBuffer() got = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
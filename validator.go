@@ -0,0 +1,42 @@
+package apdu
+
+import "fmt"
+
+// ResponseValidator checks the Rapdu received in response to the Capdu c, e.g. the length or TLV structure of the
+// response data, and returns an error if it is invalid.
+type ResponseValidator func(c Capdu, r Rapdu) error
+
+type validatorKey struct {
+	cla, ins byte
+}
+
+var responseValidators = make(map[validatorKey]ResponseValidator)
+
+// RegisterResponseValidator registers v to be invoked by ParseRapduFor for responses to commands with the given CLA
+// and INS, replacing any validator registered before. A nil v removes the validator. It is not safe to call
+// concurrently with parsing and is meant to be called once during initialisation.
+func RegisterResponseValidator(cla, ins byte, v ResponseValidator) {
+	if v == nil {
+		delete(responseValidators, validatorKey{cla: cla, ins: ins})
+		return
+	}
+
+	responseValidators[validatorKey{cla: cla, ins: ins}] = v
+}
+
+// ParseRapduFor calls ParseRapdu and additionally invokes the validator registered with RegisterResponseValidator for
+// the CLA and INS of the command c that elicited the response, if any. The error of the validator is wrapped.
+func ParseRapduFor(c Capdu, b []byte) (Rapdu, error) {
+	r, err := ParseRapdu(b)
+	if err != nil {
+		return Rapdu{}, err
+	}
+
+	if v, ok := responseValidators[validatorKey{cla: c.CLA, ins: c.INS}]; ok {
+		if err = v(c, r); err != nil {
+			return Rapdu{}, fmt.Errorf("%s: invalid response to %02X%02X: %w", packageTag, c.CLA, c.INS, err)
+		}
+	}
+
+	return r, nil
+}
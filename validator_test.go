@@ -0,0 +1,86 @@
+package apdu_test
+
+import (
+	"errors"
+	"github.com/nvx/go-apdu"
+	"reflect"
+	"testing"
+)
+
+// TestParseRapduFor is not parallel as it registers package wide response validators.
+func TestParseRapduFor(t *testing.T) {
+	apdu.RegisterResponseValidator(0x00, apdu.InsSelect, func(_ apdu.Capdu, r apdu.Rapdu) error {
+		if r.IsSuccess() && (len(r.Data) < 2 || r.Data[0] != 0x6F) {
+			return errors.New("response data is not an FCI template")
+		}
+
+		return nil
+	})
+	defer apdu.RegisterResponseValidator(0x00, apdu.InsSelect, nil)
+
+	selectMF := apdu.Capdu{CLA: 0x00, INS: apdu.InsSelect, P1: 0x00, P2: 0x00, Data: []byte{0x3F, 0x00}, Ne: 256}
+
+	tests := []struct {
+		name    string
+		c       apdu.Capdu
+		b       []byte
+		want    apdu.Rapdu
+		wantErr bool
+	}{
+		{
+			name: "valid FCI",
+			c:    selectMF,
+			b:    []byte{0x6F, 0x00, 0x90, 0x00},
+			want: apdu.Rapdu{Data: []byte{0x6F, 0x00}, SW1: 0x90, SW2: 0x00},
+		},
+		{
+			name: "error status word passed to validator",
+			c:    selectMF,
+			b:    []byte{0x6A, 0x82},
+			want: apdu.Rapdu{SW1: 0x6A, SW2: 0x82},
+		},
+		{
+			name:    "error: invalid FCI",
+			c:       selectMF,
+			b:       []byte{0x01, 0x02, 0x90, 0x00},
+			wantErr: true,
+		},
+		{
+			name: "other CLA not validated",
+			c:    apdu.Capdu{CLA: 0x80, INS: apdu.InsSelect},
+			b:    []byte{0x01, 0x02, 0x90, 0x00},
+			want: apdu.Rapdu{Data: []byte{0x01, 0x02}, SW1: 0x90, SW2: 0x00},
+		},
+		{
+			name: "other INS not validated",
+			c:    apdu.Capdu{CLA: 0x00, INS: apdu.InsReadBinary, Ne: 256},
+			b:    []byte{0x01, 0x02, 0x90, 0x00},
+			want: apdu.Rapdu{Data: []byte{0x01, 0x02}, SW1: 0x90, SW2: 0x00},
+		},
+		{
+			name:    "error: invalid length",
+			c:       selectMF,
+			b:       []byte{0x90},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := apdu.ParseRapduFor(tt.c, tt.b)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseRapduFor() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseRapduFor() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	apdu.RegisterResponseValidator(0x00, apdu.InsSelect, nil)
+	if _, err := apdu.ParseRapduFor(selectMF, []byte{0x01, 0x02, 0x90, 0x00}); err != nil {
+		t.Errorf("ParseRapduFor() after removal error = %v, want nil", err)
+	}
+}